@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	customSensu "las/accs/entities-status/sensu"
+
+	"github.com/sensu/sensu-go/types"
+	"github.com/sensu/sensu-plugin-sdk/sensu"
+)
+
+func runDiff() {
+	plugin := sensu.NewGoCheck(&config.PluginConfig, options, checkDiffArgs, executeDiff, false)
+	plugin.Execute()
+}
+
+func checkDiffArgs(event *types.Event) (int, error) {
+	if len(config.DiffFrom) == 0 {
+		return sensu.CheckStateCritical, errors.New("--from must be set to the snapshot file to compare from")
+	}
+	if len(config.DiffTo) == 0 {
+		// Compare to the live entities status
+		if status, err := checkArgs(event); err != nil {
+			return status, err
+		}
+	}
+
+	formatter, err := parseDiffOutput()
+	if err != nil {
+		return sensu.CheckStateCritical, err
+	}
+	config.diffFormatter = formatter
+	return sensu.CheckStateOK, nil
+}
+
+// parseDiffOutput : Get the formatter of the --output or --template-file custom output, if any,
+// or of the --sensu-format format, for the diff subcommand
+func parseDiffOutput() (customSensu.DiffFormatter, error) {
+	if !hasCustomOutput() {
+		formatter, err := customSensu.GetDiffFormatter(config.SensuFormat)
+		if err != nil {
+			return nil, fmt.Errorf("--sensu-format: %w", err)
+		}
+		if delimited, ok := formatter.(customSensu.DelimitedFormatter); ok {
+			delimited.NoHeaders = config.NoHeaders
+			return delimited, nil
+		}
+		return formatter, nil
+	}
+
+	formatter, err := parseOutput()
+	if err != nil {
+		return nil, err
+	}
+	// Template and jsonpath custom outputs render snapshot differences too
+	diffFormatter, ok := formatter.(customSensu.DiffFormatter)
+	if !ok {
+		return nil, errors.New("custom output is not supported by diff")
+	}
+	return diffFormatter, nil
+}
+
+// executeDiff : Print the entities that appeared, disappeared, got worse or recovered between two snapshots,
+// or between a snapshot and the live entities status
+func executeDiff(event *types.Event) (int, error) {
+	setLogLevel()
+
+	from, err := customSensu.LoadSnapshot(config.DiffFrom)
+	if err != nil {
+		return sensu.CheckStateCritical, err
+	}
+
+	var to customSensu.Snapshot
+	if len(config.DiffTo) > 0 {
+		to, err = customSensu.LoadSnapshot(config.DiffTo)
+		if err != nil {
+			return sensu.CheckStateCritical, err
+		}
+	} else {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		agg, err := collectStatus(ctx, nil)
+		if err != nil {
+			return sensu.CheckStateCritical, err
+		}
+		to = newSnapshot(agg.Snapshot(config.Namespace))
+		if len(config.SaveSnapshot) > 0 {
+			if err := customSensu.SaveSnapshot(config.SaveSnapshot, to); err != nil {
+				return sensu.CheckStateCritical, err
+			}
+		}
+	}
+
+	diff := customSensu.DiffSnapshots(from, to)
+	err = writeOutput(func(w io.Writer) error {
+		return config.diffFormatter.FormatDiff(w, diff)
+	})
+	if err != nil {
+		return sensu.CheckStateCritical, err
+	}
+
+	if exit, ok := config.diffFormatter.(customSensu.DiffExitStatuser); ok {
+		return exit.DiffExitStatus(diff), nil
+	}
+	return sensu.CheckStateOK, nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	customSensu "las/accs/entities-status/sensu"
+
+	"github.com/sensu/sensu-go/types"
+	"github.com/sensu/sensu-plugin-sdk/sensu"
+)
+
+// timelineFormats : Output formats of the history subcommand
+var timelineFormats = map[string]func(w io.Writer, periods []customSensu.TimelinePeriod) error{
+	"tabular": customSensu.WriteTimelineTable,
+	"wide":    customSensu.WriteTimelineTable,
+	"json":    customSensu.WriteTimelineJSON,
+	"yaml":    customSensu.WriteTimelineYAML,
+}
+
+func historyStore() customSensu.HistoryStore {
+	return customSensu.HistoryStore{Path: config.HistoryFile, Retention: config.historyRetention}
+}
+
+// recordHistory : Append a collection run to the history file, when one is used, after setting the
+// time in current status and the flap count of the entities from the previous runs
+func recordHistory(agg *customSensu.Aggregator, statusMap map[string]customSensu.EntityStatus) error {
+	if len(config.HistoryFile) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	return historyStore().Update(agg.HistoryRecord(config.Namespace, now), func(records []customSensu.HistoryRecord) {
+		customSensu.ApplyHistory(statusMap, records, now)
+	})
+}
+
+func runHistory() {
+	// The entity may be given as first argument: history ENTITY [flags].
+	// It is turned into --entity as the command line rejects positional arguments
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		os.Args = append([]string{os.Args[0], "--entity", os.Args[1]}, os.Args[2:]...)
+	}
+
+	plugin := sensu.NewGoCheck(&config.PluginConfig, options, checkHistoryArgs, executeHistory, false)
+	plugin.Execute()
+}
+
+func checkHistoryArgs(event *types.Event) (int, error) {
+	if len(config.HistoryFile) == 0 {
+		return sensu.CheckStateCritical, errors.New("--history-file must be set")
+	} else if len(config.Entity) == 0 {
+		return sensu.CheckStateCritical, errors.New("entity must be given as argument or with --entity")
+	} else if len(config.Namespace) == 0 {
+		return sensu.CheckStateCritical, errors.New("--namespace flag or $SENSU_NAMESPACE environment variable must be set")
+	}
+	if _, ok := timelineFormats[config.SensuFormat]; !ok {
+		return sensu.CheckStateCritical, fmt.Errorf("--sensu-format: format %q is not supported by history, authorized values: json, tabular, wide, yaml", config.SensuFormat)
+	}
+	return sensu.CheckStateOK, nil
+}
+
+// executeHistory : Print the status timeline of an entity
+func executeHistory(event *types.Event) (int, error) {
+	setLogLevel()
+
+	records, err := historyStore().Load(config.Namespace)
+	if err != nil {
+		return sensu.CheckStateCritical, err
+	}
+
+	periods := customSensu.GetEntityTimeline(records, config.Entity)
+	if len(periods) == 0 {
+		return sensu.CheckStateCritical, fmt.Errorf("no history for entity %q in namespace %q", config.Entity, config.Namespace)
+	}
+
+	err = writeOutput(func(w io.Writer) error {
+		return timelineFormats[config.SensuFormat](w, periods)
+	})
+	if err != nil {
+		return sensu.CheckStateCritical, err
+	}
+
+	return sensu.CheckStateOK, nil
+}
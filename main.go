@@ -1,9 +1,15 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	customSensu "las/accs/entities-status/sensu"
 
@@ -22,6 +28,44 @@ type Config struct {
 	SensuAccessToken string
 	SensuFormat      string
 	Debug            bool
+	Watch            string
+	Listen           string
+	GroupLabel       string
+	Cluster          string
+	Labels           []string
+	NoHeaders        bool
+	Output           string
+	TemplateFile     string
+	SortBy           []string
+	Columns          []string
+	NoColor          bool
+	OutputFile       string
+	JUnitFailOn      []string
+	SaveSnapshot     string
+	DiffFrom         string
+	DiffTo           string
+	HistoryFile      string
+	HistoryRetention string
+	Entity           string
+	Window           string
+	SLOTarget        float64
+	SLOFailOn        []string
+	Rollup           string
+	Input            string
+	PageSize         int
+	Lean             bool
+	API              string
+	watchInterval    time.Duration
+	timeout          time.Duration
+	historyRetention time.Duration
+	junitFailOn      []int
+	formatter        customSensu.Formatter
+	diffFormatter    customSensu.DiffFormatter
+	reportFormatter  customSensu.ReportFormatter
+	reportWindow     time.Duration
+	sloFailOn        []int
+	rollup           customSensu.Rollup
+	source           customSensu.EventSource
 }
 
 var (
@@ -67,7 +111,7 @@ var (
 			Argument:  "sensu-format",
 			Shorthand: "",
 			Default:   "tabular",
-			Usage:     "Sensu Format (defaults to $SENSU_FORMAT). Authorized values: tabular, yaml wrapped-json",
+			Usage:     "Sensu Format (defaults to $SENSU_FORMAT). Authorized values: tabular, wide, yaml, wrapped-json, json, prometheus, influx, graphite, csv, tsv, markdown, html, junit, nagios",
 			Value:     &config.SensuFormat,
 		},
 		&sensu.PluginConfigOption[bool]{
@@ -79,60 +123,659 @@ var (
 			Usage:     "Activate debug logs",
 			Value:     &config.Debug,
 		},
+		&sensu.PluginConfigOption[string]{
+			Path:      "watch",
+			Env:       "",
+			Argument:  "watch",
+			Shorthand: "w",
+			Default:   "",
+			Usage:     "Refresh the entities status at the given interval (e.g. 30s) until interrupted",
+			Value:     &config.Watch,
+		},
+		&sensu.PluginConfigOption[string]{
+			Path:      "listen",
+			Env:       "",
+			Argument:  "listen",
+			Shorthand: "",
+			Default:   "127.0.0.1:8091",
+			Usage:     "Address the serve subcommand listens on",
+			Value:     &config.Listen,
+		},
+		&sensu.PluginConfigOption[string]{
+			Path:      "group-label",
+			Env:       "",
+			Argument:  "group-label",
+			Shorthand: "",
+			Default:   "",
+			Usage:     "Entity label used to group entities (defaults to the entity class)",
+			Value:     &config.GroupLabel,
+		},
+		&sensu.PluginConfigOption[string]{
+			Path:      "cluster",
+			Env:       "",
+			Argument:  "cluster",
+			Shorthand: "",
+			Default:   "",
+			Usage:     "Cluster name added to the influx and graphite outputs",
+			Value:     &config.Cluster,
+		},
+		&sensu.SlicePluginConfigOption[string]{
+			Path:      "labels",
+			Env:       "",
+			Argument:  "labels",
+			Shorthand: "",
+			Default:   []string{},
+			Usage:     "Entity labels added as columns to the csv and tsv outputs",
+			Value:     &config.Labels,
+		},
+		&sensu.PluginConfigOption[bool]{
+			Path:      "no-headers",
+			Env:       "",
+			Argument:  "no-headers",
+			Shorthand: "",
+			Default:   false,
+			Usage:     "Do not print the header row of the csv and tsv outputs",
+			Value:     &config.NoHeaders,
+		},
+		&sensu.PluginConfigOption[string]{
+			Path:      "output",
+			Env:       "",
+			Argument:  "output",
+			Shorthand: "o",
+			Default:   "",
+			Usage:     "Custom output overriding --sensu-format: template=TEMPLATE or jsonpath=TEMPLATE",
+			Value:     &config.Output,
+		},
+		&sensu.PluginConfigOption[string]{
+			Path:      "template-file",
+			Env:       "",
+			Argument:  "template-file",
+			Shorthand: "",
+			Default:   "",
+			Usage:     "Go text/template file used to render the output, overriding --sensu-format",
+			Value:     &config.TemplateFile,
+		},
+		&sensu.SlicePluginConfigOption[string]{
+			Path:      "sort-by",
+			Env:       "",
+			Argument:  "sort-by",
+			Shorthand: "",
+			Default:   []string{},
+			Usage:     "Columns the tabular and wide outputs are sorted by, with an optional :asc or :desc suffix (defaults to status,entity)",
+			Value:     &config.SortBy,
+		},
+		&sensu.SlicePluginConfigOption[string]{
+			Path:      "columns",
+			Env:       "",
+			Argument:  "columns",
+			Shorthand: "",
+			Default:   []string{},
+			Usage:     "Columns of the tabular and wide outputs: entity, status, events, silenced, critical, warning, unknown, ok, namespace, class, subscriptions, last-seen, worst-check, since, flaps, failure-ratio, streak, recovered",
+			Value:     &config.Columns,
+		},
+		&sensu.PluginConfigOption[bool]{
+			Path:      "no-color",
+			Env:       "",
+			Argument:  "no-color",
+			Shorthand: "",
+			Default:   false,
+			Usage:     "Do not colour the status cells of the tabular and wide outputs",
+			Value:     &config.NoColor,
+		},
+		&sensu.PluginConfigOption[string]{
+			Path:      "output-file",
+			Env:       "",
+			Argument:  "output-file",
+			Shorthand: "",
+			Default:   "",
+			Usage:     "Write the output to the given file, atomically replaced, instead of stdout",
+			Value:     &config.OutputFile,
+		},
+		&sensu.SlicePluginConfigOption[string]{
+			Path:      "junit-fail-on",
+			Env:       "",
+			Argument:  "junit-fail-on",
+			Shorthand: "",
+			Default:   []string{"critical", "warning"},
+			Usage:     "Check statuses reported as failures by the junit output: critical, warning, unknown",
+			Value:     &config.JUnitFailOn,
+		},
+		&sensu.PluginConfigOption[string]{
+			Path:      "save-snapshot",
+			Env:       "",
+			Argument:  "save-snapshot",
+			Shorthand: "",
+			Default:   "",
+			Usage:     "Save the entities status to the given snapshot file",
+			Value:     &config.SaveSnapshot,
+		},
+		&sensu.PluginConfigOption[string]{
+			Path:      "from",
+			Env:       "",
+			Argument:  "from",
+			Shorthand: "",
+			Default:   "",
+			Usage:     "Snapshot file the diff subcommand compares from",
+			Value:     &config.DiffFrom,
+		},
+		&sensu.PluginConfigOption[string]{
+			Path:      "to",
+			Env:       "",
+			Argument:  "to",
+			Shorthand: "",
+			Default:   "",
+			Usage:     "Snapshot file the diff subcommand compares to (defaults to the live entities status)",
+			Value:     &config.DiffTo,
+		},
+		&sensu.PluginConfigOption[string]{
+			Path:      "history-file",
+			Env:       "",
+			Argument:  "history-file",
+			Shorthand: "",
+			Default:   "",
+			Usage:     "File every collection run is appended to, used to compute the time in status and flap count of the entities",
+			Value:     &config.HistoryFile,
+		},
+		&sensu.PluginConfigOption[string]{
+			Path:      "history-retention",
+			Env:       "",
+			Argument:  "history-retention",
+			Shorthand: "",
+			Default:   "168h",
+			Usage:     "How long collection runs are kept in the history file",
+			Value:     &config.HistoryRetention,
+		},
+		&sensu.PluginConfigOption[string]{
+			Path:      "entity",
+			Env:       "",
+			Argument:  "entity",
+			Shorthand: "",
+			Default:   "",
+			Usage:     "Entity the history subcommand shows the timeline of",
+			Value:     &config.Entity,
+		},
+		&sensu.PluginConfigOption[string]{
+			Path:      "window",
+			Env:       "",
+			Argument:  "window",
+			Shorthand: "",
+			Default:   "720h",
+			Usage:     "Time window of the report subcommand",
+			Value:     &config.Window,
+		},
+		&sensu.PluginConfigOption[float64]{
+			Path:      "slo-target",
+			Env:       "",
+			Argument:  "slo-target",
+			Shorthand: "",
+			Default:   customSensu.DefaultSLOTarget,
+			Usage:     "Availability target of the report subcommand, in percent",
+			Value:     &config.SLOTarget,
+		},
+		&sensu.SlicePluginConfigOption[string]{
+			Path:      "slo-fail-on",
+			Env:       "",
+			Argument:  "slo-fail-on",
+			Shorthand: "",
+			Default:   []string{"critical"},
+			Usage:     "Statuses counted as unavailable by the report subcommand: critical, warning, unknown",
+			Value:     &config.SLOFailOn,
+		},
+		&sensu.PluginConfigOption[string]{
+			Path:      "rollup",
+			Env:       "",
+			Argument:  "rollup",
+			Shorthand: "",
+			Default:   "",
+			Usage:     "Report a check as failing when it failed in N of its last M executions, given as N/M",
+			Value:     &config.Rollup,
+		},
+		&sensu.PluginConfigOption[string]{
+			Path:      "input",
+			Env:       "",
+			Argument:  "input",
+			Shorthand: "",
+			Default:   "",
+			Usage:     "Read the events from the given file (- for stdin) instead of the Sensu API: JSON array, newline-delimited JSON or sensuctl wrapped-json/yaml dump",
+			Value:     &config.Input,
+		},
+		&sensu.PluginConfigOption[int]{
+			Path:      "page-size",
+			Env:       "",
+			Argument:  "page-size",
+			Shorthand: "",
+			Default:   customSensu.DefaultPageSize,
+			Usage:     "Number of events requested per page from the Sensu API",
+			Value:     &config.PageSize,
+		},
+		&sensu.PluginConfigOption[bool]{
+			Path:      "lean",
+			Env:       "",
+			Argument:  "lean",
+			Shorthand: "",
+			Default:   false,
+			Usage:     "Only fetch the entity name, check name, status, silences and execution times of the events, through the GraphQL API",
+			Value:     &config.Lean,
+		},
+		&sensu.PluginConfigOption[string]{
+			Path:      "api",
+			Env:       "",
+			Argument:  "api",
+			Shorthand: "",
+			Default:   "rest",
+			Usage:     "Sensu API the events are collected from: rest, or graphql to fetch the entities with their events and silences",
+			Value:     &config.API,
+		},
+		&sensu.PluginConfigOption[string]{
+			Path:      "timeout",
+			Env:       "",
+			Argument:  "timeout",
+			Shorthand: "",
+			Default:   customSensu.DefaultTimeout.String(),
+			Usage:     "Time limit of every request to the Sensu API, including the read of its response",
+			Value:     &config.Timeout,
+		},
 	}
 )
 
+// subcommands : Alternative plugin modes, selected by the first command line argument
+var subcommands = map[string]func(){
+	"diff":    runDiff,
+	"history": runHistory,
+	"mutator": runMutator,
+	"report":  runReport,
+	"serve":   runServe,
+	"top":     runTop,
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		if run, ok := subcommands[os.Args[1]]; ok {
+			os.Args = append(os.Args[:1], os.Args[2:]...)
+			run()
+			return
+		}
+	}
+
 	plugin := sensu.NewGoCheck(&config.PluginConfig, options, checkArgs, executeCheck, false)
 	plugin.Execute()
 }
 
+func runMutator() {
+	mutator := sensu.NewGoMutator(&config.PluginConfig, options, checkMutatorArgs, executeMutator)
+	mutator.Execute()
+}
+
 func checkArgs(event *types.Event) (int, error) {
-	if len(config.SensuAPIUrl) == 0 {
-		return sensu.CheckStateCritical, errors.New("--sensu-api-url flag or $SENSU_API_URL environment variable must be set")
-	} else if len(config.Namespace) == 0 {
-		return sensu.CheckStateCritical, errors.New("--namespace flag or $SENSU_NAMESPACE environment variable must be set")
+	// Offline, the namespace is optional when the input holds a single namespace
+	if len(config.Input) == 0 {
+		if len(config.SensuAPIUrl) == 0 {
+			return sensu.CheckStateCritical, errors.New("--sensu-api-url flag or $SENSU_API_URL environment variable must be set")
+		} else if len(config.Namespace) == 0 {
+			return sensu.CheckStateCritical, errors.New("--namespace flag or $SENSU_NAMESPACE environment variable must be set")
+		}
+	}
+	if config.API != "rest" && config.API != "graphql" {
+		return sensu.CheckStateCritical, fmt.Errorf("--api must be rest or graphql, got %q", config.API)
+	}
+	if config.Lean && config.API == "graphql" {
+		return sensu.CheckStateCritical, errors.New("--lean is not supported with --api graphql, which already fetches the entities with their events")
+	} else if config.Lean && len(config.Input) > 0 {
+		return sensu.CheckStateCritical, errors.New("--lean is not supported with --input, the events are read from a file")
+	}
+	if config.PageSize <= 0 {
+		return sensu.CheckStateCritical, fmt.Errorf("--page-size must be positive, got %d", config.PageSize)
+	}
+	if err := parseTimeout(); err != nil {
+		return sensu.CheckStateCritical, err
 	}
+	if len(config.Watch) > 0 {
+		interval, err := time.ParseDuration(config.Watch)
+		if err != nil || interval <= 0 {
+			return sensu.CheckStateCritical, fmt.Errorf("--watch must be a positive duration, got %q", config.Watch)
+		}
+		config.watchInterval = interval
+	}
+	if err := customSensu.ValidateColumns(config.Columns); err != nil {
+		return sensu.CheckStateCritical, fmt.Errorf("--columns: %w", err)
+	}
+	if err := customSensu.ValidateSortBy(config.SortBy); err != nil {
+		return sensu.CheckStateCritical, fmt.Errorf("--sort-by: %w", err)
+	}
+	if len(config.HistoryRetention) > 0 {
+		retention, err := time.ParseDuration(config.HistoryRetention)
+		if err != nil || retention <= 0 {
+			return sensu.CheckStateCritical, fmt.Errorf("--history-retention must be a positive duration, got %q", config.HistoryRetention)
+		}
+		config.historyRetention = retention
+	}
+	config.junitFailOn = nil
+	for _, name := range config.JUnitFailOn {
+		status, err := customSensu.ParseStatus(name)
+		if err != nil {
+			return sensu.CheckStateCritical, fmt.Errorf("--junit-fail-on: %w", err)
+		}
+		config.junitFailOn = append(config.junitFailOn, status)
+	}
+	rollup, err := customSensu.ParseRollup(config.Rollup)
+	if err != nil {
+		return sensu.CheckStateCritical, fmt.Errorf("--rollup: %w", err)
+	}
+	config.rollup = rollup
+	config.source = newEventSource()
+	if len(config.Namespace) == 0 {
+		if err := inputNamespace(); err != nil {
+			return sensu.CheckStateCritical, err
+		}
+	}
+	formatter, err := parseOutput()
+	if err != nil {
+		return sensu.CheckStateCritical, err
+	}
+	config.formatter = formatter
 	return sensu.CheckStateOK, nil
 }
 
-func printResult(statusMap map[string]customSensu.EntityStatus) {
-	// Depending on format different output is possible
-	if config.SensuFormat == "tabular" {
-		customSensu.PrintTabularResult(statusMap)
-	} else if config.SensuFormat == "yaml" {
-		customSensu.PrintYAMLResult(statusMap)
-	} else if config.SensuFormat == "wrapped-json" {
-		customSensu.PrintJSONResult(statusMap)
-	} else {
-		fmt.Fprintln(os.Stderr, "Invalid format output")
+func checkMutatorArgs(event *types.Event) error {
+	if config.Input == "-" {
+		return errors.New("--input - is not supported by the mutator, the event is read from stdin")
+	} else if len(config.Input) == 0 && len(config.SensuAPIUrl) == 0 {
+		return errors.New("--sensu-api-url flag or $SENSU_API_URL environment variable must be set")
+	}
+	if config.Lean {
+		return errors.New("--lean is not supported by the mutator, the events of the entity are fetched from the REST API")
 	}
+	if err := parseTimeout(); err != nil {
+		return err
+	}
+	if !event.HasCheck() || event.Entity == nil {
+		return errors.New("event must contain an entity and a check")
+	}
+	if len(config.Namespace) == 0 {
+		// Default to the namespace of the event being mutated
+		config.Namespace = event.Namespace
+	}
+	rollup, err := customSensu.ParseRollup(config.Rollup)
+	if err != nil {
+		return fmt.Errorf("--rollup: %w", err)
+	}
+	config.rollup = rollup
+	config.source = newEventSource()
+	return nil
 }
 
-func executeCheck(event *types.Event) (int, error) {
+// inputNamespace : Default the namespace to the one of the offline input. Entities are only known by
+// their name in the status, those of several namespaces would be merged
+func inputNamespace() error {
+	namespaces, err := config.source.ListNamespaces(context.Background())
+	if err != nil {
+		return err
+	}
+	if len(namespaces) > 1 {
+		return fmt.Errorf("--namespace must be set, the input holds several namespaces: %s", strings.Join(namespaces, ", "))
+	} else if len(namespaces) == 1 {
+		config.Namespace = namespaces[0]
+	}
+	return nil
+}
+
+// parseTimeout : Parse the --timeout of the requests to the backend
+func parseTimeout() error {
+	timeout, err := time.ParseDuration(config.Timeout)
+	if err != nil || timeout <= 0 {
+		return fmt.Errorf("--timeout must be a positive duration, got %q", config.Timeout)
+	}
+	config.timeout = timeout
+	return nil
+}
 
+func authHeader() map[string]string {
+	return map[string]string{
+		"Authorization": fmt.Sprintf("Bearer %s", config.SensuAccessToken),
+	}
+}
+
+// parseOutput : Get the formatter of the --output or --template-file custom output, if any,
+// or of the --sensu-format format
+func parseOutput() (customSensu.Formatter, error) {
+	if len(config.TemplateFile) > 0 {
+		text, err := os.ReadFile(config.TemplateFile)
+		if err != nil {
+			return nil, err
+		}
+		tmpl, err := customSensu.ParseTemplate(string(text))
+		if err != nil {
+			return nil, err
+		}
+		return customSensu.TemplateFormatter{Template: tmpl}, nil
+	}
+
+	if len(config.Output) == 0 {
+		formatter, err := customSensu.GetFormatter(config.SensuFormat)
+		if err != nil {
+			return nil, fmt.Errorf("--sensu-format: %w", err)
+		}
+		return formatter, nil
+	}
+
+	kind, text, _ := strings.Cut(config.Output, "=")
+	switch kind {
+	case "template":
+		tmpl, err := customSensu.ParseTemplate(text)
+		if err != nil {
+			return nil, err
+		}
+		return customSensu.TemplateFormatter{Template: tmpl}, nil
+	case "jsonpath":
+		path, err := customSensu.ParseJSONPath(text)
+		if err != nil {
+			return nil, err
+		}
+		return customSensu.JSONPathFormatter{Path: path}, nil
+	default:
+		return nil, fmt.Errorf("--output must be template=TEMPLATE or jsonpath=TEMPLATE, got %q", config.Output)
+	}
+}
+
+func hasCustomOutput() bool {
+	return len(config.Output) > 0 || len(config.TemplateFile) > 0
+}
+
+// isTabularFormat : Whether the selected format is rendered by the tabular printer
+func isTabularFormat() bool {
+	return config.SensuFormat == "tabular" || config.SensuFormat == "wide"
+}
+
+// useColor : Colour the status cells when stdout is a terminal, unless disabled by --no-color or $NO_COLOR
+func useColor() bool {
+	if config.NoColor || len(os.Getenv("NO_COLOR")) > 0 || len(config.OutputFile) > 0 {
+		return false
+	}
+	return stdoutIsTerminal()
+}
+
+// stdoutIsTerminal : Whether stdout is a terminal, rather than a pipe or a file
+func stdoutIsTerminal() bool {
+	info, err := os.Stdout.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// newFormatData : Data and options handed to the output format
+func newFormatData(events []types.Event, statusMap map[string]customSensu.EntityStatus, info map[string]customSensu.EntityInfo) customSensu.FormatData {
+	return customSensu.FormatData{
+		Events:    events,
+		StatusMap: statusMap,
+		Info:      info,
+		Namespace: config.Namespace,
+		Cluster:   config.Cluster,
+		Generated: time.Now(),
+		Tabular: customSensu.TabularOptions{
+			Columns: config.Columns,
+			SortBy:  config.SortBy,
+			Color:   useColor(),
+		},
+		Labels:    config.Labels,
+		NoHeaders: config.NoHeaders,
+		JUnit:     customSensu.JUnitOptions{FailOn: config.junitFailOn},
+	}
+}
+
+// newSnapshot : Snapshot of the entities status collected now
+func newSnapshot(statusMap map[string]customSensu.EntityStatus) customSensu.Snapshot {
+	return customSensu.Snapshot{
+		Metadata: customSensu.TemplateMetadata{
+			Namespace: config.Namespace,
+			Cluster:   config.Cluster,
+			Generated: time.Now(),
+		},
+		Entities: statusMap,
+	}
+}
+
+// printResult : Write the entities status in the selected output format, to stdout or to --output-file
+func printResult(data customSensu.FormatData) error {
+	return writeOutput(func(w io.Writer) error {
+		return config.formatter.Format(w, data)
+	})
+}
+
+// writeOutput : Write to --output-file when set, to stdout otherwise
+func writeOutput(write func(w io.Writer) error) error {
+	if len(config.OutputFile) > 0 {
+		return customSensu.WriteFileAtomic(config.OutputFile, write)
+	}
+	return write(os.Stdout)
+}
+
+func setLogLevel() {
 	if config.Debug {
 		log.SetLevel(log.DebugLevel)
 	} else {
 		log.SetLevel(log.FatalLevel)
 	}
+}
 
-	endpointURL := fmt.Sprintf("%s/api/core/v2/namespaces/%s/events",
-		config.SensuAPIUrl,
-		config.Namespace,
-	)
+// newEventSource : Source of the events, the --input file offline, else the --api of the backend
+func newEventSource() customSensu.EventSource {
+	if len(config.Input) > 0 {
+		return &customSensu.FileSource{Path: config.Input}
+	}
+	rest := customSensu.RESTSource{
+		URL:    config.SensuAPIUrl,
+		Header: authHeader(),
+		Page:   customSensu.PageOptions{Size: config.PageSize, Timeout: config.timeout},
+		Lean:   config.Lean,
+	}
+	if config.API == "graphql" {
+		return customSensu.GraphQLSource{RESTSource: rest}
+	}
+	return rest
+}
 
-	header := map[string]string{
-		"Authorization": fmt.Sprintf("Bearer %s", config.SensuAccessToken),
+// sourceName : Where the events are collected from, for display
+func sourceName() string {
+	if config.Input == "-" {
+		return "stdin"
+	} else if len(config.Input) > 0 {
+		return config.Input
+	}
+	return config.SensuAPIUrl
+}
+
+// collectEvents : Events of the namespace, with the --rollup applied. Requests are cancelled with ctx
+func collectEvents(ctx context.Context) ([]types.Event, error) {
+	evts, err := config.source.ListEvents(ctx, config.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	return customSensu.ApplyRollup(evts, config.rollup), nil
+}
+
+// collectStatus : Aggregate the events of the namespace, with the --rollup applied, as they are read.
+// each, when not nil, is handed every event, for the outputs needing more than the entities status
+func collectStatus(ctx context.Context, each func(evt types.Event)) (*customSensu.Aggregator, error) {
+	agg := customSensu.NewAggregator()
+	err := config.source.StreamEvents(ctx, config.Namespace, func(evt types.Event) error {
+		evt = customSensu.RollupEvent(evt, config.rollup)
+		agg.Add(evt)
+		if each != nil {
+			each(evt)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return agg, nil
+}
+
+// keepEvents : Collect the events for the --output formats needing them, nil when the entities
+// status is enough and the events do not have to be kept
+func keepEvents(evts *[]types.Event) func(evt types.Event) {
+	if customSensu.IsStatusOnly(config.formatter) {
+		return nil
 	}
-	evts, err := customSensu.EventExtractJSONWithHeader(endpointURL, header, nil)
+	return func(evt types.Event) {
+		*evts = append(*evts, evt)
+	}
+}
+
+func executeCheck(event *types.Event) (int, error) {
+	setLogLevel()
+
+	if config.watchInterval > 0 {
+		return executeWatch(config.watchInterval)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var evts []types.Event
+	agg, err := collectStatus(ctx, keepEvents(&evts))
 	if err != nil {
 		return sensu.CheckStateCritical, err
 	}
 
-	entitiesStatus := customSensu.GetEntitiesStatus(evts)
+	statusMap := agg.Snapshot(config.Namespace)
+	if err := recordHistory(agg, statusMap); err != nil {
+		return sensu.CheckStateCritical, err
+	}
 
-	printResult(entitiesStatus)
+	data := newFormatData(evts, statusMap, agg.Info(config.Namespace))
+	if err := printResult(data); err != nil {
+		return sensu.CheckStateCritical, err
+	}
 
+	if len(config.SaveSnapshot) > 0 {
+		if err := customSensu.SaveSnapshot(config.SaveSnapshot, newSnapshot(data.StatusMap)); err != nil {
+			return sensu.CheckStateCritical, err
+		}
+	}
+
+	if exit, ok := config.formatter.(customSensu.ExitStatuser); ok {
+		return exit.ExitStatus(data), nil
+	}
 	return sensu.CheckStateOK, nil
 }
+
+func executeMutator(event *types.Event) (*types.Event, error) {
+	setLogLevel()
+
+	var evts []types.Event
+	var err error
+	if source, ok := config.source.(customSensu.EntityEventSource); ok {
+		evts, err = source.ListEntityEvents(context.Background(), config.Namespace, event.Entity.Name)
+	} else {
+		evts, err = config.source.ListEvents(context.Background(), config.Namespace)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The event being mutated is more recent than the one stored by the backend
+	evts = customSensu.ApplyRollup(customSensu.MergeEvent(evts, *event), config.rollup)
+
+	customSensu.AnnotateEvent(event, customSensu.GetEntityStatus(event.Entity.Name, evts))
+
+	return event, nil
+}
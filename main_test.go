@@ -1,8 +1,186 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
+
+	customSensu "las/accs/entities-status/sensu"
+
+	"github.com/sensu/sensu-go/types"
+	"github.com/sensu/sensu-plugin-sdk/sensu"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestMain(t *testing.T) {
 }
+
+// fakeSource : Events of two entities in the default namespace and one in production
+func fakeSource() *customSensu.FakeSource {
+	evt1 := *types.FixtureEvent("localhost", "dummy-check1")
+	evt1.Check.Status = sensu.CheckStateCritical
+	evt2 := *types.FixtureEvent("localhost", "dummy-check2")
+	evt3 := *types.FixtureEvent("localhost2", "dummy-check1")
+	evt4 := *types.FixtureEvent("localhost3", "dummy-check1")
+	evt4.Namespace = "production"
+	evt4.Entity.Namespace = "production"
+
+	source := &customSensu.FakeSource{}
+	source.Events = []types.Event{evt1, evt2, evt3, evt4}
+	return source
+}
+
+func TestExecuteCheckFakeSource(t *testing.T) {
+	assert := assert.New(t)
+	defer func(saved Config) { config = saved }(config)
+
+	output := filepath.Join(t.TempDir(), "status.json")
+	formatter, err := customSensu.GetFormatter("json")
+	assert.NoError(err)
+	config.source = fakeSource()
+	config.formatter = formatter
+	config.Namespace = "default"
+	config.OutputFile = output
+
+	// The json output only needs the entities status, the events are not kept
+	var evts []types.Event
+	assert.Nil(keepEvents(&evts))
+
+	status, err := executeCheck(nil)
+	assert.NoError(err)
+	assert.Equal(sensu.CheckStateOK, status)
+
+	raw, err := os.ReadFile(output)
+	assert.NoError(err)
+	var statusMap map[string]customSensu.EntityStatus
+	assert.NoError(json.Unmarshal(raw, &statusMap))
+	assert.Len(statusMap, 2)
+	assert.Equal(sensu.CheckStateCritical, statusMap["localhost"].Status)
+	assert.Equal(2, statusMap["localhost"].Total)
+
+	// Neither does the default output, the details of the entities are aggregated too
+	formatter, err = customSensu.GetFormatter("tabular")
+	assert.NoError(err)
+	config.formatter = formatter
+	config.Columns = []string{"entity", "status", "worst-check"}
+	assert.Nil(keepEvents(&evts))
+	_, err = executeCheck(nil)
+	assert.NoError(err)
+	raw, err = os.ReadFile(output)
+	assert.NoError(err)
+	assert.Contains(string(raw), "localhost|     CRIT|dummy-check1\n")
+
+	// Errors of the source are reported as critical
+	config.source = &customSensu.FakeSource{Err: errors.New("backend unavailable")}
+	status, err = executeCheck(nil)
+	assert.ErrorContains(err, "backend unavailable")
+	assert.Equal(sensu.CheckStateCritical, status)
+}
+
+func TestCollectEventsRollup(t *testing.T) {
+	assert := assert.New(t)
+	defer func(saved Config) { config = saved }(config)
+
+	source := fakeSource()
+	// One failure out of the last four executions
+	source.Events[0].Check.History = []types.CheckHistory{{Status: 0, Executed: 1}, {Status: 2, Executed: 2}, {Status: 0, Executed: 3}}
+	source.Events[0].Check.Status = sensu.CheckStateOK
+	source.Events[0].Check.Executed = 4
+	config.source = source
+	config.Namespace = "default"
+	config.rollup = customSensu.Rollup{Failing: 1, Executions: 4}
+
+	evts, err := collectEvents(context.Background())
+	assert.NoError(err)
+	assert.Len(evts, 3)
+	assert.Equal(sensu.CheckStateCritical, customSensu.GetEntitiesStatus(evts)["localhost"].Status)
+	// The events of the source are left untouched
+	assert.Equal(uint32(sensu.CheckStateOK), source.Events[0].Check.Status)
+}
+
+func TestInputNamespace(t *testing.T) {
+	assert := assert.New(t)
+	defer func(saved Config) { config = saved }(config)
+
+	// Entities of the same name in several namespaces would be merged
+	config.source = fakeSource()
+	config.Namespace = ""
+	assert.ErrorContains(inputNamespace(), "several namespaces: default, production")
+
+	source := fakeSource()
+	source.Events = source.Events[:3]
+	config.source = source
+	assert.NoError(inputNamespace())
+	assert.Equal("default", config.Namespace)
+
+	// Events read offline may only carry the namespace of their entity
+	evt := *types.FixtureEvent("localhost", "dummy-check1")
+	evt.Namespace = ""
+	evt.Check.Status = sensu.CheckStateCritical
+	source = &customSensu.FakeSource{}
+	source.Events = []types.Event{evt}
+	config.source = source
+	config.Namespace = ""
+	assert.NoError(inputNamespace())
+	assert.Equal("default", config.Namespace)
+
+	output := filepath.Join(t.TempDir(), "status.json")
+	formatter, err := customSensu.GetFormatter("json")
+	assert.NoError(err)
+	config.formatter = formatter
+	config.OutputFile = output
+	_, err = executeCheck(nil)
+	assert.NoError(err)
+	raw, err := os.ReadFile(output)
+	assert.NoError(err)
+	var statusMap map[string]customSensu.EntityStatus
+	assert.NoError(json.Unmarshal(raw, &statusMap))
+	assert.Equal(sensu.CheckStateCritical, statusMap["localhost"].Status)
+}
+
+func TestExecuteMutatorFakeSource(t *testing.T) {
+	assert := assert.New(t)
+	defer func(saved Config) { config = saved }(config)
+
+	config.source = fakeSource()
+	config.Namespace = "default"
+	config.rollup = customSensu.Rollup{}
+
+	// The mutated event replaces the critical one stored by the source
+	event := types.FixtureEvent("localhost", "dummy-check1")
+	event.Timestamp++
+	event, err := executeMutator(event)
+	assert.NoError(err)
+	assert.Equal("0", event.Annotations[customSensu.AnnotationPrefix+"status"])
+	assert.Equal("2", event.Annotations[customSensu.AnnotationPrefix+"total"])
+}
+
+func TestPrintWatchResultRedirected(t *testing.T) {
+	assert := assert.New(t)
+	defer func(saved Config) { config = saved }(config)
+	defer func(saved *os.File) { os.Stdout = saved }(os.Stdout)
+
+	formatter, err := customSensu.GetFormatter("tabular")
+	assert.NoError(err)
+	config.formatter = formatter
+	config.SensuFormat = "tabular"
+	config.OutputFile = ""
+
+	out, err := os.Create(filepath.Join(t.TempDir(), "watch.txt"))
+	assert.NoError(err)
+	defer out.Close()
+	os.Stdout = out
+
+	statusMap := map[string]customSensu.EntityStatus{"localhost": {Status: sensu.CheckStateOK, Ok: 1, Total: 1}}
+	assert.NoError(printWatchResult(newFormatData(nil, statusMap, nil), nil, time.Minute))
+
+	// The screen is not cleared in redirected output
+	raw, err := os.ReadFile(out.Name())
+	assert.NoError(err)
+	assert.NotContains(string(raw), clearScreen)
+	assert.Contains(string(raw), "localhost|")
+}
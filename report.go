@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	customSensu "las/accs/entities-status/sensu"
+
+	"github.com/sensu/sensu-go/types"
+	"github.com/sensu/sensu-plugin-sdk/sensu"
+)
+
+func runReport() {
+	plugin := sensu.NewGoCheck(&config.PluginConfig, options, checkReportArgs, executeReport, false)
+	plugin.Execute()
+}
+
+func checkReportArgs(event *types.Event) (int, error) {
+	if status, err := checkArgs(event); err != nil {
+		return status, err
+	}
+
+	window, err := time.ParseDuration(config.Window)
+	if err != nil || window <= 0 {
+		return sensu.CheckStateCritical, fmt.Errorf("--window must be a positive duration, got %q", config.Window)
+	}
+	config.reportWindow = window
+
+	if config.SLOTarget <= 0 || config.SLOTarget >= 100 {
+		return sensu.CheckStateCritical, fmt.Errorf("--slo-target must be between 0 and 100 excluded, got %g", config.SLOTarget)
+	}
+
+	config.sloFailOn = nil
+	for _, name := range config.SLOFailOn {
+		status, err := customSensu.ParseStatus(name)
+		if err != nil {
+			return sensu.CheckStateCritical, fmt.Errorf("--slo-fail-on: %w", err)
+		}
+		config.sloFailOn = append(config.sloFailOn, status)
+	}
+
+	formatter, err := parseReportOutput()
+	if err != nil {
+		return sensu.CheckStateCritical, err
+	}
+	config.reportFormatter = formatter
+	return sensu.CheckStateOK, nil
+}
+
+// parseReportOutput : Get the formatter of the --output or --template-file custom output, if any,
+// or of the --sensu-format format, for the report subcommand
+func parseReportOutput() (customSensu.ReportFormatter, error) {
+	if !hasCustomOutput() {
+		formatter, err := customSensu.GetReportFormatter(config.SensuFormat)
+		if err != nil {
+			return nil, fmt.Errorf("--sensu-format: %w", err)
+		}
+		if delimited, ok := formatter.(customSensu.DelimitedFormatter); ok {
+			delimited.NoHeaders = config.NoHeaders
+			return delimited, nil
+		}
+		return formatter, nil
+	}
+
+	formatter, err := parseOutput()
+	if err != nil {
+		return nil, err
+	}
+	// Template and jsonpath custom outputs render availability reports too
+	reportFormatter, ok := formatter.(customSensu.ReportFormatter)
+	if !ok {
+		return nil, errors.New("custom output is not supported by report")
+	}
+	return reportFormatter, nil
+}
+
+// executeReport : Print the availability of the entities and of their groups over the window.
+// Status samples come from the history file when one is used, from the check history of the events otherwise
+func executeReport(event *types.Event) (int, error) {
+	setLogLevel()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	evts, err := collectEvents(ctx)
+	if err != nil {
+		return sensu.CheckStateCritical, err
+	}
+
+	var samples map[string][]customSensu.StatusSample
+	if len(config.HistoryFile) > 0 {
+		records, err := historyStore().Load(config.Namespace)
+		if err != nil {
+			return sensu.CheckStateCritical, err
+		}
+		samples = customSensu.GetHistorySamples(records)
+	} else {
+		samples = customSensu.GetEntitiesSamples(customSensu.GetChecksSamples(evts), config.sloFailOn)
+	}
+
+	now := time.Now()
+	report := customSensu.NewAvailabilityReport(
+		samples,
+		customSensu.GetEntitiesGroups(evts, config.GroupLabel),
+		customSensu.TemplateMetadata{
+			Namespace: config.Namespace,
+			Cluster:   config.Cluster,
+			Generated: now,
+		},
+		customSensu.AvailabilityOptions{
+			From:   now.Add(-config.reportWindow),
+			To:     now,
+			Target: config.SLOTarget,
+			FailOn: config.sloFailOn,
+		},
+	)
+
+	err = writeOutput(func(w io.Writer) error {
+		return config.reportFormatter.FormatReport(w, report)
+	})
+	if err != nil {
+		return sensu.CheckStateCritical, err
+	}
+
+	return sensu.CheckStateOK, nil
+}
@@ -0,0 +1,291 @@
+package sensu
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	v2 "github.com/sensu/core/v2"
+	"github.com/sensu/sensu-plugin-sdk/sensu"
+)
+
+// checkKey : Identifies the event of a check of an entity
+type checkKey struct {
+	Namespace string
+	Entity    string
+	Check     string
+}
+
+// entityKey : Identifies an entity
+type entityKey struct {
+	Namespace string
+	Entity    string
+}
+
+// entityRecord : Details of an entity, from its most recent event
+type entityRecord struct {
+	Timestamp int64
+	Info      EntityInfo
+}
+
+// checkRecord : What the entity status needs from the last event of a check, so the events do not
+// have to be kept in memory
+type checkRecord struct {
+	Timestamp int64
+	Status    int
+	Silenced  bool
+	// Executions and failures in the check history, consecutive failures up to the last execution
+	// and whether the check failed before its last execution
+	Executed  int
+	Failed    int
+	Streak    int
+	Recovered bool
+}
+
+func newCheckRecord(evt v2.Event) checkRecord {
+	record := checkRecord{
+		Timestamp: evt.Timestamp,
+		Status:    int(evt.Check.Status),
+		Silenced:  evt.IsSilenced(),
+	}
+
+	samples := checkSamples(evt.Check)
+	for i, sample := range samples {
+		record.Executed++
+		if sample.Status == sensu.CheckStateOK {
+			record.Streak = 0
+			continue
+		}
+		record.Failed++
+		record.Streak++
+		if i < len(samples)-1 {
+			record.Recovered = true
+		}
+	}
+	// The history only holds the last executions, Occurrences keeps counting
+	if record.Status != sensu.CheckStateOK && int(evt.Check.Occurrences) > record.Streak {
+		record.Streak = int(evt.Check.Occurrences)
+	}
+
+	return record
+}
+
+// Aggregator : Entities status computed one event at a time. Events can be added from several
+// goroutines, as they are fetched, and aggregators filled separately can be merged.
+// An event replaces the previous event of the same check of the same entity, unless it is older
+type Aggregator struct {
+	mu       sync.Mutex
+	checks   map[checkKey]checkRecord
+	entities map[entityKey]entityRecord
+}
+
+// NewAggregator : Create an empty aggregator
+func NewAggregator() *Aggregator {
+	return &Aggregator{checks: make(map[checkKey]checkRecord), entities: make(map[entityKey]entityRecord)}
+}
+
+// Add : Account for an event in the status of its entity. Events without entity or check are ignored
+func (a *Aggregator) Add(evt v2.Event) {
+	if evt.Entity == nil || evt.Check == nil {
+		return
+	}
+	key := checkKey{Namespace: eventNamespace(evt), Entity: evt.Entity.Name, Check: evt.Check.Name}
+	record := newCheckRecord(evt)
+	entity := entityRecord{Timestamp: evt.Timestamp, Info: newEntityInfo(evt.Entity)}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.set(key, record)
+	a.setEntity(entityKey{Namespace: key.Namespace, Entity: key.Entity}, entity)
+}
+
+// Merge : Account for the events added to another aggregator
+func (a *Aggregator) Merge(other *Aggregator) {
+	if other == a {
+		return
+	}
+
+	other.mu.Lock()
+	checks := make(map[checkKey]checkRecord, len(other.checks))
+	for key, record := range other.checks {
+		checks[key] = record
+	}
+	entities := make(map[entityKey]entityRecord, len(other.entities))
+	for key, entity := range other.entities {
+		entities[key] = entity
+	}
+	other.mu.Unlock()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for key, record := range checks {
+		a.set(key, record)
+	}
+	for key, entity := range entities {
+		a.setEntity(key, entity)
+	}
+}
+
+// set : Keep the most recent record of a check, the last one set when they have the same timestamp
+func (a *Aggregator) set(key checkKey, record checkRecord) {
+	if previous, ok := a.checks[key]; ok && previous.Timestamp > record.Timestamp {
+		return
+	}
+	a.checks[key] = record
+}
+
+// setEntity : Keep the details of an entity from its most recent event
+func (a *Aggregator) setEntity(key entityKey, entity entityRecord) {
+	if previous, ok := a.entities[key]; ok && previous.Timestamp > entity.Timestamp {
+		return
+	}
+	a.entities[key] = entity
+}
+
+// Namespaces : Namespaces of the events accounted for so far, sorted
+func (a *Aggregator) Namespaces() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	set := make(map[string]bool)
+	for key := range a.checks {
+		set[key.Namespace] = true
+	}
+	namespaces := make([]string, 0, len(set))
+	for namespace := range set {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+	return namespaces
+}
+
+// Snapshot : Status of the entities of a namespace accounted for so far.
+// Entities of the same name in other namespaces are left out
+func (a *Aggregator) Snapshot(namespace string) map[string]EntityStatus {
+	return a.snapshot(func(key checkKey) bool { return key.Namespace == namespace })
+}
+
+// snapshot : Status of the entities of the checks matching, by entity name
+func (a *Aggregator) snapshot(match func(key checkKey) bool) map[string]EntityStatus {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	set := make(map[string]EntityStatus)
+	executed := make(map[string]int)
+	failed := make(map[string]int)
+	recovered := make(map[string]bool)
+
+	for key, record := range a.checks {
+		if !match(key) {
+			continue
+		}
+		estatus := set[key.Entity]
+
+		estatus.Total++
+		if record.Silenced {
+			estatus.Silenced++
+		}
+		if record.Status == sensu.CheckStateCritical {
+			estatus.Critical++
+		} else if record.Status == sensu.CheckStateWarning {
+			estatus.Warning++
+		} else if record.Status == sensu.CheckStateUnknown {
+			estatus.Unknown++
+		} else {
+			estatus.Ok++
+		}
+
+		if !record.Silenced {
+			estatus.Status = calculateStatus(estatus.Status, record.Status)
+			executed[key.Entity] += record.Executed
+			failed[key.Entity] += record.Failed
+			recovered[key.Entity] = recovered[key.Entity] || record.Recovered
+			if record.Streak > estatus.FailingStreak {
+				estatus.FailingStreak = record.Streak
+			}
+		}
+
+		set[key.Entity] = estatus
+	}
+
+	for entity, estatus := range set {
+		if executed[entity] > 0 {
+			estatus.FailureRatio = float64(failed[entity]) / float64(executed[entity])
+		}
+		estatus.Recovered = estatus.Status == sensu.CheckStateOK && recovered[entity]
+		set[entity] = estatus
+	}
+
+	return set
+}
+
+// Info : Details of the entities of a namespace accounted for so far, as GetEntitiesInfo returns them
+func (a *Aggregator) Info(namespace string) map[string]EntityInfo {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	infos := make(map[string]EntityInfo)
+	for key, entity := range a.entities {
+		if key.Namespace == namespace {
+			infos[key.Entity] = entity.Info
+		}
+	}
+
+	worst := make(map[string]int)
+	for key, record := range a.checks {
+		if key.Namespace != namespace || record.Silenced || record.Status == sensu.CheckStateOK {
+			continue
+		}
+		info := infos[key.Entity]
+		severity := StatusSeverity(record.Status)
+		if severity > worst[key.Entity] || (severity == worst[key.Entity] && key.Check < info.WorstCheck) {
+			worst[key.Entity] = severity
+			info.WorstCheck = key.Check
+			infos[key.Entity] = info
+		}
+	}
+	return infos
+}
+
+// HistoryRecord : History record of the entities of a namespace accounted for so far, see NewHistoryRecord
+func (a *Aggregator) HistoryRecord(namespace string, ts time.Time) HistoryRecord {
+	return a.historyRecord(func(key checkKey) bool { return key.Namespace == namespace }, namespace, ts)
+}
+
+// historyRecord : History record of the entities of the checks matching
+func (a *Aggregator) historyRecord(match func(key checkKey) bool, namespace string, ts time.Time) HistoryRecord {
+	record := HistoryRecord{Time: ts, Namespace: namespace, Entities: make(map[string]HistoryEntity)}
+	for entity, status := range a.snapshot(match) {
+		record.Entities[entity] = HistoryEntity{Status: status.Status, Checks: make(map[string]int)}
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for key, check := range a.checks {
+		if match(key) {
+			record.Entities[key.Entity].Checks[key.Check] = check.Status
+		}
+	}
+	return record
+}
+
+// eventCounts : Number of events of every entity of the checks matching, by severity and silencing
+func (a *Aggregator) eventCounts(match func(key checkKey) bool) map[string]map[string]map[bool]int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	counts := make(map[string]map[string]map[bool]int)
+	for key, record := range a.checks {
+		if !match(key) {
+			continue
+		}
+		if _, ok := counts[key.Entity]; !ok {
+			counts[key.Entity] = make(map[string]map[bool]int)
+			for _, severity := range prometheusSeverities {
+				counts[key.Entity][severity] = map[bool]int{false: 0, true: 0}
+			}
+		}
+		counts[key.Entity][severityName(record.Status)][record.Silenced]++
+	}
+	return counts
+}
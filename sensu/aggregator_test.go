@@ -0,0 +1,115 @@
+package sensu
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	corev2 "github.com/sensu/core/v2"
+	"github.com/sensu/sensu-plugin-sdk/sensu"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAggregatorAdd(t *testing.T) {
+	assert := assert.New(t)
+
+	agg := NewAggregator()
+	assert.Empty(agg.Snapshot("default"))
+
+	evt1 := *corev2.FixtureEvent("localhost", "dummy-check1")
+	evt1.Check.Status = sensu.CheckStateCritical
+	evt2 := *corev2.FixtureEvent("localhost", "dummy-check2")
+	agg.Add(evt1)
+	agg.Add(evt2)
+	agg.Add(corev2.Event{})
+
+	statuses := agg.Snapshot("default")
+	assert.Len(statuses, 1)
+	assert.Equal(sensu.CheckStateCritical, statuses["localhost"].Status)
+	assert.Equal(2, statuses["localhost"].Total)
+
+	// A newer event of the same check replaces the previous one
+	recovered := evt1
+	recovered.Timestamp++
+	recovered.Check = &corev2.Check{}
+	*recovered.Check = *evt1.Check
+	recovered.Check.Status = sensu.CheckStateOK
+	agg.Add(recovered)
+	assert.Equal(sensu.CheckStateOK, agg.Snapshot("default")["localhost"].Status)
+	assert.Equal(2, agg.Snapshot("default")["localhost"].Total)
+
+	// An older one is ignored
+	agg.Add(evt1)
+	assert.Equal(sensu.CheckStateOK, agg.Snapshot("default")["localhost"].Status)
+
+	// The namespace of the entity is used when the event has none
+	orphan := *corev2.FixtureEvent("localhost2", "dummy-check1")
+	orphan.Namespace = ""
+	orphan.Check.Status = sensu.CheckStateCritical
+	agg.Add(orphan)
+	assert.Equal(sensu.CheckStateCritical, agg.Snapshot("default")["localhost2"].Status)
+	assert.Equal([]string{"default"}, agg.Namespaces())
+}
+
+func TestAggregatorMerge(t *testing.T) {
+	assert := assert.New(t)
+
+	evt1 := *corev2.FixtureEvent("localhost", "dummy-check1")
+	evt2 := *corev2.FixtureEvent("localhost", "dummy-check2")
+	evt2.Check.Status = sensu.CheckStateWarning
+	evt3 := *corev2.FixtureEvent("localhost2", "dummy-check1")
+	// Same entity and check, in another namespace
+	evt4 := *corev2.FixtureEvent("localhost2", "dummy-check1")
+	evt4.Namespace = "production"
+
+	agg1 := NewAggregator()
+	agg1.Add(evt1)
+	agg1.Add(evt3)
+	agg2 := NewAggregator()
+	agg2.Add(evt2)
+	agg2.Add(evt4)
+
+	agg1.Merge(agg2)
+	agg1.Merge(agg1)
+
+	assert.Equal(GetEntitiesStatus([]corev2.Event{evt1, evt2, evt3}), agg1.Snapshot("default"))
+	assert.Equal(sensu.CheckStateWarning, agg1.Snapshot("default")["localhost"].Status)
+	// Entities of the same name are kept apart in every namespace
+	assert.Equal(1, agg1.Snapshot("default")["localhost2"].Total)
+	assert.Equal(map[string]EntityStatus{"localhost2": {Total: 1, Ok: 1}}, agg1.Snapshot("production"))
+	assert.Equal([]string{"default", "production"}, agg1.Namespaces())
+	// The merged aggregator is left untouched
+	assert.Len(agg2.Snapshot("default"), 1)
+	assert.Len(agg2.Snapshot("production"), 1)
+	assert.Equal(1, agg2.Snapshot("default")["localhost"].Total)
+}
+
+func TestAggregatorConcurrentAdd(t *testing.T) {
+	assert := assert.New(t)
+
+	agg := NewAggregator()
+	var wg sync.WaitGroup
+	for producer := 0; producer < 8; producer++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				evt := *corev2.FixtureEvent(fmt.Sprintf("entity%d", i), fmt.Sprintf("check%d", producer))
+				if producer == 0 {
+					evt.Check.Status = sensu.CheckStateCritical
+				}
+				agg.Add(evt)
+			}
+			agg.Snapshot("default")
+		}()
+	}
+	wg.Wait()
+
+	statuses := agg.Snapshot("default")
+	assert.Len(statuses, 100)
+	for _, status := range statuses {
+		assert.Equal(8, status.Total)
+		assert.Equal(1, status.Critical)
+		assert.Equal(sensu.CheckStateCritical, status.Status)
+	}
+}
@@ -0,0 +1,429 @@
+package sensu
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/apex/log"
+	v2 "github.com/sensu/core/v2"
+	"github.com/sensu/sensu-plugin-sdk/sensu"
+	"gopkg.in/yaml.v2"
+)
+
+// DefaultSLOTarget : Availability target of the report, in percent
+const DefaultSLOTarget = 99.9
+
+// StatusSample : Status observed at a point in time, as a Unix timestamp
+type StatusSample struct {
+	Time   int64
+	Status int
+}
+
+// GetChecksSamples : Status samples of every check of every entity, from the check history Sensu keeps
+// with the events (the last 21 executions by default)
+func GetChecksSamples(events []v2.Event) map[string]map[string][]StatusSample {
+	samples := make(map[string]map[string][]StatusSample)
+
+	for _, evt := range events {
+		if evt.Entity == nil || evt.Check == nil {
+			continue
+		}
+		if _, ok := samples[evt.Entity.Name]; !ok {
+			samples[evt.Entity.Name] = make(map[string][]StatusSample)
+		}
+		samples[evt.Entity.Name][evt.Check.Name] = checkSamples(evt.Check)
+	}
+
+	return samples
+}
+
+// checkSamples : Status samples of a check, oldest first, including its last execution
+func checkSamples(check *v2.Check) []StatusSample {
+	var samples []StatusSample
+	for _, history := range check.History {
+		if history.Executed <= 0 {
+			// Never executed
+			continue
+		}
+		samples = append(samples, StatusSample{Time: history.Executed, Status: int(history.Status)})
+	}
+	// The history may not hold the last execution yet
+	if n := len(samples); check.Executed > 0 && (n == 0 || samples[n-1].Time < check.Executed) {
+		samples = append(samples, StatusSample{Time: check.Executed, Status: int(check.Status)})
+	}
+	sort.SliceStable(samples, func(i, j int) bool { return samples[i].Time < samples[j].Time })
+	return samples
+}
+
+// GetEntitiesSamples : Status samples of every entity, merging the samples of its checks.
+// An entity fails whenever one of its checks is in one of the failOn statuses, see MergeSamples
+func GetEntitiesSamples(checks map[string]map[string][]StatusSample, failOn []int) map[string][]StatusSample {
+	samples := make(map[string][]StatusSample)
+	for entity, checkSamples := range checks {
+		samples[entity] = MergeSamples(checkSamples, failOn)
+	}
+	return samples
+}
+
+// GetHistorySamples : Status samples of every entity, from the history file records
+func GetHistorySamples(records []HistoryRecord) map[string][]StatusSample {
+	samples := make(map[string][]StatusSample)
+	for _, record := range records {
+		for entity, recorded := range record.Entities {
+			samples[entity] = append(samples[entity], StatusSample{Time: record.Time.Unix(), Status: recorded.Status})
+		}
+	}
+	return samples
+}
+
+// MergeSamples : Status of several sources over time. The merged status is failing whenever one of the
+// sources is in one of the failOn statuses, critical only when empty: it is the worst of the failing
+// statuses then, as calculateStatus ranks them, and the worst of every status otherwise.
+// A source is ignored before its first sample
+func MergeSamples(sources map[string][]StatusSample, failOn []int) []StatusSample {
+	if len(failOn) == 0 {
+		failOn = []int{sensu.CheckStateCritical}
+	}
+
+	type change struct {
+		source string
+		StatusSample
+	}
+
+	var changes []change
+	for source, samples := range sources {
+		for _, sample := range samples {
+			changes = append(changes, change{source: source, StatusSample: sample})
+		}
+	}
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].Time < changes[j].Time })
+
+	var merged []StatusSample
+	current := make(map[string]int)
+	for i, c := range changes {
+		current[c.source] = c.Status
+		if i+1 < len(changes) && changes[i+1].Time == c.Time {
+			// Apply every change of a point in time at once
+			continue
+		}
+
+		// A warning ranks above an unknown status, which may be the only failing one
+		status, failing := sensu.CheckStateOK, false
+		for _, sourceStatus := range current {
+			if slices.Contains(failOn, sourceStatus) {
+				if !failing {
+					status, failing = sourceStatus, true
+				} else {
+					status = calculateStatus(status, sourceStatus)
+				}
+			} else if !failing {
+				status = calculateStatus(status, sourceStatus)
+			}
+		}
+		if n := len(merged); n == 0 || merged[n-1].Status != status {
+			merged = append(merged, StatusSample{Time: c.Time, Status: status})
+		}
+	}
+
+	return merged
+}
+
+// AvailabilityOptions : Time window, target and failing statuses of an availability report
+type AvailabilityOptions struct {
+	From time.Time
+	To   time.Time
+	// Target is the availability objective, in percent
+	Target float64
+	// FailOn are the statuses counted as unavailable, critical only when empty
+	FailOn []int
+}
+
+// Availability : Availability of an entity or of a group over the report window.
+// Durations are in seconds, MTBF is 0 when no failure happened
+type Availability struct {
+	Name string `json:"name" yaml:"name"`
+	// Availability is the percentage of the observed time spent in an available status
+	Availability float64 `json:"availability" yaml:"availability"`
+	// ErrorBudgetConsumed is the percentage of the allowed downtime (100 - target) already spent
+	ErrorBudgetConsumed float64 `json:"error_budget_consumed" yaml:"error_budget_consumed"`
+	Failures            int     `json:"failures" yaml:"failures"`
+	Observed            float64 `json:"observed" yaml:"observed"`
+	Downtime            float64 `json:"downtime" yaml:"downtime"`
+	MTTR                float64 `json:"mttr" yaml:"mttr"`
+	MTBF                float64 `json:"mtbf" yaml:"mtbf"`
+}
+
+// GetAvailability : Compute the availability over the window of a sequence of status samples, oldest first.
+// Every sample status lasts until the next sample, the last one until the end of the window
+func GetAvailability(name string, samples []StatusSample, opts AvailabilityOptions) Availability {
+	failOn := opts.FailOn
+	if len(failOn) == 0 {
+		failOn = []int{sensu.CheckStateCritical}
+	}
+	from, to := opts.From.Unix(), opts.To.Unix()
+
+	availability := Availability{Name: name, Availability: 100}
+	var uptime, downtime int64
+	down := false
+	for i, sample := range samples {
+		start := max(sample.Time, from)
+		end := to
+		if i+1 < len(samples) {
+			end = min(samples[i+1].Time, to)
+		}
+		if end <= start {
+			continue
+		}
+
+		if slices.Contains(failOn, sample.Status) {
+			if !down {
+				availability.Failures++
+			}
+			down = true
+			downtime += end - start
+		} else {
+			down = false
+			uptime += end - start
+		}
+	}
+
+	observed := uptime + downtime
+	availability.Observed = float64(observed)
+	availability.Downtime = float64(downtime)
+	if observed > 0 {
+		availability.Availability = 100 * float64(uptime) / float64(observed)
+		if budget := (100 - opts.Target) / 100 * float64(observed); budget > 0 {
+			availability.ErrorBudgetConsumed = 100 * float64(downtime) / budget
+		}
+	}
+	if availability.Failures > 0 {
+		availability.MTTR = float64(downtime) / float64(availability.Failures)
+		availability.MTBF = float64(uptime) / float64(availability.Failures)
+	}
+
+	return availability
+}
+
+// AvailabilityReport : Availability of the entities and of their groups, least available first
+type AvailabilityReport struct {
+	Metadata TemplateMetadata `json:"metadata" yaml:"metadata"`
+	From     time.Time        `json:"from" yaml:"from"`
+	To       time.Time        `json:"to" yaml:"to"`
+	Target   float64          `json:"target" yaml:"target"`
+	Entities []Availability   `json:"entities" yaml:"entities"`
+	Groups   []Availability   `json:"groups" yaml:"groups"`
+}
+
+// NewAvailabilityReport : Compute the availability of every entity, and of every group of entities.
+// A group is unavailable whenever one of its entities is
+func NewAvailabilityReport(samples map[string][]StatusSample, groups map[string]string, metadata TemplateMetadata, opts AvailabilityOptions) AvailabilityReport {
+	ctx := log.WithFields(log.Fields{
+		"file":     "sensu/availability.go",
+		"function": "NewAvailabilityReport",
+	})
+
+	report := AvailabilityReport{
+		Metadata: metadata,
+		From:     opts.From,
+		To:       opts.To,
+		Target:   opts.Target,
+		Entities: []Availability{},
+		Groups:   []Availability{},
+	}
+
+	members := make(map[string]map[string][]StatusSample)
+	for entity, entitySamples := range samples {
+		report.Entities = append(report.Entities, GetAvailability(entity, entitySamples, opts))
+
+		group, ok := groups[entity]
+		if !ok {
+			group = UngroupedName
+		}
+		if _, ok := members[group]; !ok {
+			members[group] = make(map[string][]StatusSample)
+		}
+		members[group][entity] = entitySamples
+	}
+	for group, groupSamples := range members {
+		report.Groups = append(report.Groups, GetAvailability(group, MergeSamples(groupSamples, opts.FailOn), opts))
+	}
+
+	ctx.Debugf("Availability of %d entities and %d groups", len(report.Entities), len(report.Groups))
+	sortAvailability(report.Entities)
+	sortAvailability(report.Groups)
+	return report
+}
+
+func sortAvailability(availability []Availability) {
+	sort.Slice(availability, func(i, j int) bool {
+		if availability[i].Availability != availability[j].Availability {
+			return availability[i].Availability < availability[j].Availability
+		}
+		return availability[i].Name < availability[j].Name
+	})
+}
+
+// ReportFormatter : Output format of the availability report
+type ReportFormatter interface {
+	FormatReport(w io.Writer, report AvailabilityReport) error
+}
+
+// ReportFormatterFunc : Adapter allowing a function to be used as a ReportFormatter
+type ReportFormatterFunc func(w io.Writer, report AvailabilityReport) error
+
+// FormatReport : Call f(w, report)
+func (f ReportFormatterFunc) FormatReport(w io.Writer, report AvailabilityReport) error {
+	return f(w, report)
+}
+
+// reportFormats : Registry of the availability report output formats, by name
+var reportFormats = map[string]ReportFormatter{
+	"tabular":  ReportFormatterFunc(WriteReportTable),
+	"wide":     ReportFormatterFunc(WriteReportTable),
+	"json":     ReportFormatterFunc(WriteReportJSON),
+	"yaml":     ReportFormatterFunc(WriteReportYAML),
+	"markdown": ReportFormatterFunc(WriteReportMarkdown),
+	"csv":      DelimitedFormatter{Comma: ','},
+	"tsv":      DelimitedFormatter{Comma: '\t'},
+}
+
+// GetReportFormatter : Look up an availability report output format by name
+func GetReportFormatter(name string) (ReportFormatter, error) {
+	formatter, ok := reportFormats[name]
+	if !ok {
+		names := make([]string, 0, len(reportFormats))
+		for name := range reportFormats {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("format %q is not supported by report, authorized values: %s", name, strings.Join(names, ", "))
+	}
+	return formatter, nil
+}
+
+// formatSeconds : Render a duration in seconds, "-" when zero
+func formatSeconds(seconds float64) string {
+	if seconds == 0 {
+		return "-"
+	}
+	return (time.Duration(seconds) * time.Second).String()
+}
+
+// availabilityCells : Availability, error budget consumed, failures, downtime, MTTR and MTBF cells
+func availabilityCells(availability Availability) []string {
+	return []string{
+		fmt.Sprintf("%.3f%%", availability.Availability),
+		fmt.Sprintf("%.1f%%", availability.ErrorBudgetConsumed),
+		strconv.Itoa(availability.Failures),
+		formatSeconds(availability.Downtime),
+		formatSeconds(availability.MTTR),
+		formatSeconds(availability.MTBF),
+	}
+}
+
+// WriteReportTable : Write the availability report as two tables, entities then groups
+func WriteReportTable(w io.Writer, report AvailabilityReport) error {
+	fmt.Fprintf(w, "Availability from %s to %s, target %g%%\n",
+		report.From.Local().Format("2006-01-02 15:04:05"),
+		report.To.Local().Format("2006-01-02 15:04:05"),
+		report.Target,
+	)
+
+	for _, section := range []struct {
+		header string
+		rows   []Availability
+	}{{"Entity", report.Entities}, {"Group", report.Groups}} {
+		fmt.Fprintln(w)
+		tw := tabwriter.NewWriter(w, 1, 1, 3, ' ', tabwriter.AlignRight|tabwriter.Debug)
+		fmt.Fprintf(tw, "%s\tAvailability\tBudget Used\tFailures\tDowntime\tMTTR\tMTBF\n", section.header)
+		fmt.Fprintf(tw, "%s\t------------\t-----------\t--------\t--------\t----\t----\n", strings.Repeat("-", len(section.header)))
+		for _, availability := range section.rows {
+			fmt.Fprintf(tw, "%s\t%s\n", availability.Name, strings.Join(availabilityCells(availability), "\t"))
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteReportJSON : Write the availability report in JSON format
+func WriteReportJSON(w io.Writer, report AvailabilityReport) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(report)
+}
+
+// WriteReportYAML : Write the availability report in YAML format
+func WriteReportYAML(w io.Writer, report AvailabilityReport) error {
+	raw, err := yaml.Marshal(report)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(raw)
+	return err
+}
+
+// WriteReportDelimited : Write the availability report as delimiter separated values, one row per entity and group,
+// without header row when noHeaders is set. Comma separated values follow RFC 4180
+func WriteReportDelimited(w io.Writer, report AvailabilityReport, comma rune, noHeaders bool) error {
+	cw := csv.NewWriter(w)
+	cw.Comma = comma
+	cw.UseCRLF = comma == ','
+
+	if !noHeaders {
+		if err := cw.Write([]string{"kind", "name", "availability", "error_budget_consumed", "failures", "observed", "downtime", "mttr", "mtbf"}); err != nil {
+			return err
+		}
+	}
+	for _, section := range []struct {
+		kind string
+		rows []Availability
+	}{{"entity", report.Entities}, {"group", report.Groups}} {
+		for _, availability := range section.rows {
+			err := cw.Write([]string{
+				section.kind,
+				availability.Name,
+				strconv.FormatFloat(availability.Availability, 'f', 3, 64),
+				strconv.FormatFloat(availability.ErrorBudgetConsumed, 'f', 1, 64),
+				strconv.Itoa(availability.Failures),
+				strconv.FormatFloat(availability.Observed, 'f', 0, 64),
+				strconv.FormatFloat(availability.Downtime, 'f', 0, 64),
+				strconv.FormatFloat(availability.MTTR, 'f', 0, 64),
+				strconv.FormatFloat(availability.MTBF, 'f', 0, 64),
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteReportMarkdown : Write the availability report as a markdown report
+func WriteReportMarkdown(w io.Writer, report AvailabilityReport) error {
+	fmt.Fprintf(w, "## Availability - %s\n\n", report.Metadata.Namespace)
+	fmt.Fprintf(w, "From %s to %s, target **%g%%**\n", report.From.Format(time.RFC1123), report.To.Format(time.RFC1123), report.Target)
+
+	for _, section := range []struct {
+		title string
+		rows  []Availability
+	}{{"Entities", report.Entities}, {"Groups", report.Groups}} {
+		fmt.Fprintf(w, "\n### %s\n\n", section.title)
+		fmt.Fprintln(w, "| Name | Availability | Budget used | Failures | Downtime | MTTR | MTBF |")
+		fmt.Fprintln(w, "|------|-------------:|------------:|---------:|---------:|-----:|-----:|")
+		for _, availability := range section.rows {
+			fmt.Fprintf(w, "| %s | %s |\n", markdownEscaper.Replace(availability.Name), strings.Join(availabilityCells(availability), " | "))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package sensu
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	corev2 "github.com/sensu/core/v2"
+	"github.com/sensu/sensu-plugin-sdk/sensu"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeSamples(t *testing.T) {
+	assert := assert.New(t)
+
+	merged := MergeSamples(map[string][]StatusSample{
+		"disk": {{10, sensu.CheckStateOK}, {20, sensu.CheckStateCritical}, {30, sensu.CheckStateOK}},
+		"cpu":  {{15, sensu.CheckStateWarning}, {30, sensu.CheckStateOK}},
+	}, nil)
+	assert.Equal([]StatusSample{
+		{10, sensu.CheckStateOK},
+		{15, sensu.CheckStateWarning},
+		{20, sensu.CheckStateCritical},
+		{30, sensu.CheckStateOK},
+	}, merged)
+
+	// An unknown source fails even though a warning ranks above it
+	sources := map[string][]StatusSample{
+		"disk": {{10, sensu.CheckStateOK}, {20, sensu.CheckStateUnknown}, {30, sensu.CheckStateOK}},
+		"cpu":  {{15, sensu.CheckStateWarning}, {40, sensu.CheckStateOK}},
+	}
+	assert.Equal([]StatusSample{
+		{10, sensu.CheckStateOK},
+		{15, sensu.CheckStateWarning},
+		{20, sensu.CheckStateUnknown},
+		{30, sensu.CheckStateWarning},
+		{40, sensu.CheckStateOK},
+	}, MergeSamples(sources, []int{sensu.CheckStateUnknown}))
+	assert.Equal([]StatusSample{
+		{10, sensu.CheckStateOK},
+		{15, sensu.CheckStateWarning},
+		{40, sensu.CheckStateOK},
+	}, MergeSamples(sources, nil))
+}
+
+func TestGetChecksSamples(t *testing.T) {
+	assert := assert.New(t)
+
+	disk := corev2.FixtureEvent("web", "disk")
+	disk.Check.History = []corev2.CheckHistory{{Status: 0, Executed: 100}, {Status: 2, Executed: 160}}
+	disk.Check.Executed = 220
+	disk.Check.Status = sensu.CheckStateOK
+	cpu := corev2.FixtureEvent("web", "cpu")
+	cpu.Check.History = []corev2.CheckHistory{{Status: 1, Executed: 130}}
+	cpu.Check.Executed = 130
+	cpu.Check.Status = sensu.CheckStateWarning
+
+	samples := GetChecksSamples([]corev2.Event{*disk, *cpu})
+	assert.Equal([]StatusSample{{100, 0}, {160, 2}, {220, 0}}, samples["web"]["disk"])
+	assert.Equal([]StatusSample{{130, 1}}, samples["web"]["cpu"])
+	assert.Equal([]StatusSample{{100, 0}, {130, 1}, {160, 2}, {220, 1}}, GetEntitiesSamples(samples, nil)["web"])
+}
+
+func TestGetAvailability(t *testing.T) {
+	assert := assert.New(t)
+
+	opts := AvailabilityOptions{From: time.Unix(1000, 0), To: time.Unix(2000, 0), Target: 99}
+	samples := []StatusSample{
+		{900, sensu.CheckStateOK},
+		{1200, sensu.CheckStateCritical},
+		{1300, sensu.CheckStateOK},
+		{1500, sensu.CheckStateCritical},
+		{1600, sensu.CheckStateWarning},
+	}
+
+	assert.Equal(Availability{
+		Name:                "web",
+		Availability:        80,
+		ErrorBudgetConsumed: 2000,
+		Failures:            2,
+		Observed:            1000,
+		Downtime:            200,
+		MTTR:                100,
+		MTBF:                400,
+	}, GetAvailability("web", samples, opts))
+
+	// Warnings counted as unavailable
+	opts.FailOn = []int{sensu.CheckStateCritical, sensu.CheckStateWarning}
+	availability := GetAvailability("web", samples, opts)
+	assert.Equal(2, availability.Failures)
+	assert.Equal(float64(600), availability.Downtime)
+
+	// No sample in the window
+	availability = GetAvailability("web", nil, opts)
+	assert.Equal(float64(100), availability.Availability)
+	assert.Equal(float64(0), availability.Observed)
+}
+
+func TestNewAvailabilityReport(t *testing.T) {
+	assert := assert.New(t)
+
+	opts := AvailabilityOptions{From: time.Unix(1000, 0).UTC(), To: time.Unix(2000, 0).UTC(), Target: 99.9}
+	report := NewAvailabilityReport(
+		map[string][]StatusSample{
+			"web1": {{1000, sensu.CheckStateOK}, {1500, sensu.CheckStateCritical}, {1600, sensu.CheckStateOK}},
+			"web2": {{1000, sensu.CheckStateOK}, {1550, sensu.CheckStateCritical}, {1700, sensu.CheckStateOK}},
+			"db":   {{1000, sensu.CheckStateOK}},
+		},
+		map[string]string{"web1": "web", "web2": "web"},
+		TemplateMetadata{Namespace: "default"},
+		opts,
+	)
+
+	var names []string
+	for _, availability := range report.Entities {
+		names = append(names, availability.Name)
+	}
+	assert.Equal([]string{"web2", "web1", "db"}, names)
+
+	// The web group is down from the first failure of web1 to the recovery of web2
+	assert.Equal("web", report.Groups[0].Name)
+	assert.Equal(float64(200), report.Groups[0].Downtime)
+	assert.Equal(1, report.Groups[0].Failures)
+	assert.Equal(UngroupedName, report.Groups[1].Name)
+
+	var buf bytes.Buffer
+	assert.NoError(WriteReportTable(&buf, report))
+	assert.Contains(buf.String(), "         web|        80.000%|      20000.0%|          1|      3m20s|   3m20s|13m20s\n")
+	assert.Contains(buf.String(), "Group|")
+
+	formatter, err := GetReportFormatter("csv")
+	assert.NoError(err)
+	buf.Reset()
+	assert.NoError(formatter.FormatReport(&buf, report))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\r\n")
+	assert.Equal("kind,name,availability,error_budget_consumed,failures,observed,downtime,mttr,mtbf", lines[0])
+	assert.Equal("entity,web2,85.000,15000.0,1,1000,150,150,850", lines[1])
+	assert.Equal("group,web,80.000,20000.0,1,1000,200,200,800", lines[4])
+
+	buf.Reset()
+	assert.NoError(DelimitedFormatter{Comma: '\t', NoHeaders: true}.FormatReport(&buf, report))
+	assert.True(strings.HasPrefix(buf.String(), "entity\tweb2\t85.000\t15000.0\t1\t1000\t150\t150\t850\n"))
+
+	_, err = GetReportFormatter("prometheus")
+	assert.Error(err)
+}
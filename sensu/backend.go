@@ -1,25 +1,73 @@
 package sensu
 
 import (
-	"bytes"
+	"compress/gzip"
+	"context"
 	b64 "encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/apex/log"
 	v2 "github.com/sensu/core/v2"
 )
 
-// NbEventMaxPerIter : Number of event to retrieve per request
+// DefaultPageSize : Number of event to retrieve per request
 // it's going to set pagination value
 // Refer to the following URL for detail:
 // https://docs.sensu.io/sensu-go/latest/api/overview/#pagination
-var NbEventMaxPerIter uint = 200
+const DefaultPageSize = 200
+
+// PageOptions : Pagination of the events requests
+type PageOptions struct {
+	// Size is the number of events per page, DefaultPageSize when 0
+	Size int
+	// Prefetch is the number of pages requested ahead of the one being decoded, 1 when 0
+	Prefetch int
+	// Timeout is the time limit of every request, DefaultTimeout when 0. It covers the wait for the
+	// response headers, then the read of the body once the page is decoded, not the time a page
+	// fetched ahead waits for the previous ones to be decoded
+	Timeout time.Duration
+}
+
+// timeout : Time limit of every request
+func (p PageOptions) timeout() time.Duration {
+	if p.Timeout <= 0 {
+		return DefaultTimeout
+	}
+	return p.Timeout
+}
+
+// DefaultTimeout : Time limit of a request to the backend, including the read of its response body
+const DefaultTimeout = 30 * time.Second
+
+// errTimeout : Cause of the cancellation of the requests taking longer than their time limit
+var errTimeout = errors.New("request to the backend timed out")
+
+// timeoutCause : The timeout of a request cancelled by its time limit, err otherwise
+func timeoutCause(ctx context.Context, err error) error {
+	if cause := context.Cause(ctx); errors.Is(cause, errTimeout) {
+		return cause
+	}
+	return err
+}
+
+// withTimeout : Context of a request cancelled after timeout, see timeoutCause
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	return context.WithTimeoutCause(ctx, timeout, fmt.Errorf("%w after %s", errTimeout, timeout))
+}
+
+// HTTPClient : Client used for every request to the backend.
+// Sharing it allows connections to be kept alive between successive calls. The requests are given
+// their time limit with their context, see PageOptions
+var HTTPClient = &http.Client{}
 
 // ExtractEvents : Take json data as []byte.
 // It will return an erray of sensu event, with error
@@ -42,85 +90,350 @@ func ExtractEvents(data []byte) ([]v2.Event, error) {
 }
 
 // EventExtractJSONWithHeader :  function used to call the backend and to retrieve events.
-// Auth token have to be provided in the header map
-func EventExtractJSONWithHeader(rawURL string, header map[string]string, filter map[string]string) ([]v2.Event, error) {
-	ctx := log.WithFields(log.Fields{
+// Auth token have to be provided in the header map. The requests are cancelled with ctx
+func EventExtractJSONWithHeader(ctx context.Context, rawURL string, header map[string]string, filter map[string]string, page PageOptions) ([]v2.Event, error) {
+	logCtx := log.WithFields(log.Fields{
 		"file":     "sensu/backend.go",
 		"function": "EventExtractJSONWithHeader",
 	})
-	var method string = "GET"
-	var body string = ""
 	var eventResults []v2.Event = []v2.Event{}
 
-	reqURL, err := url.Parse(rawURL)
+	err := StreamEvents(ctx, rawURL, header, filter, page, func(evt v2.Event) error {
+		eventResults = append(eventResults, evt)
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
-	reqURLQuery := reqURL.Query()
-	reqURLQuery.Set("limit", strconv.Itoa(int(NbEventMaxPerIter)))
 
-	if len(filter) > 0 {
-		for key, value := range filter {
-			reqURLQuery.Add(key, value)
+	logCtx.Debugf("Total Reading %d events", len(eventResults))
+
+	return eventResults, nil
+}
+
+// responsePage : Response to a page request, with the time it took to get its headers
+type responsePage struct {
+	number   int
+	resp     *http.Response
+	err      error
+	latency  time.Duration
+	deadline *pageDeadline
+}
+
+// pageDeadline : Time limit of a page request. It runs while the response headers are awaited, is
+// paused while the page waits to be decoded and runs again while its body is read
+type pageDeadline struct {
+	ctx     context.Context
+	cancel  context.CancelCauseFunc
+	timer   *time.Timer
+	timeout time.Duration
+}
+
+func newPageDeadline(ctx context.Context, timeout time.Duration) *pageDeadline {
+	d := &pageDeadline{timeout: timeout}
+	d.ctx, d.cancel = context.WithCancelCause(ctx)
+	d.timer = time.AfterFunc(timeout, func() {
+		d.cancel(fmt.Errorf("%w after %s", errTimeout, timeout))
+	})
+	return d
+}
+
+func (d *pageDeadline) pause() {
+	d.timer.Stop()
+}
+
+func (d *pageDeadline) resume() {
+	d.timer.Reset(d.timeout)
+}
+
+// release : Stop the timer and cancel the request, once its body has been read
+func (d *pageDeadline) release() {
+	d.timer.Stop()
+	d.cancel(context.Canceled)
+}
+
+// StreamEvents : Call the backend and hand the events to fn one at a time, as they are decoded,
+// following the pagination. The next pages are requested while the current one is decoded, neither
+// the response bodies nor the events are kept in memory.
+// Auth token have to be provided in the header map. The requests are cancelled with ctx
+func StreamEvents(ctx context.Context, rawURL string, header map[string]string, filter map[string]string, page PageOptions, fn func(evt v2.Event) error) error {
+	return streamList(ctx, rawURL, header, filter, page, fn)
+}
+
+// streamList : Call a list endpoint of the backend and hand the objects to fn one at a time, as they
+// are decoded, following the pagination. See StreamEvents
+func streamList[T any](ctx context.Context, rawURL string, header map[string]string, filter map[string]string, page PageOptions, fn func(obj T) error) error {
+	logCtx := log.WithFields(log.Fields{
+		"file":     "sensu/backend.go",
+		"function": "streamList",
+	})
+
+	reqURL, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	size := page.Size
+	if size <= 0 {
+		size = DefaultPageSize
+	}
+	prefetch := page.Prefetch
+	if prefetch <= 0 {
+		prefetch = 1
+	}
+
+	fetchCtx, cancel := context.WithCancel(ctx)
+	pages := make(chan responsePage, prefetch)
+	go fetchPages(fetchCtx, reqURL, header, filter, page, size, pages)
+	defer func() {
+		// Stop fetching and release the pages fetched ahead
+		cancel()
+		for p := range pages {
+			if p.resp != nil {
+				p.resp.Body.Close()
+				p.deadline.release()
+			}
+		}
+	}()
+
+	total := 0
+	for p := range pages {
+		if p.err != nil {
+			return p.err
 		}
-		// body = strings.Join(filter, "%20%26%26%20")
+
+		start := time.Now()
+		count, body, err := decodePage(p, fn)
+		if err != nil {
+			// Cancelling may close the connection before the read of the body sees ctx is done
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return ctxErr
+			}
+			return err
+		}
+		total += count
+		logCtx.Debugf("Page %d: %d object(s), %d byte(s), headers in %s, transferred in %s, decoded in %s",
+			p.number, count, body.Transferred, p.latency, body.Elapsed, time.Since(start)-body.Elapsed)
+	}
+
+	// Fetching stops without an error once cancelled
+	if err := ctx.Err(); err != nil {
+		return err
 	}
+	logCtx.Errorf("Decoded %d object(s) from %s", total, reqURL.Path)
+	return nil
+}
+
+// decodePage : Decode the objects of a page and hand them to fn, its time limit running again meanwhile
+func decodePage[T any](p responsePage, fn func(obj T) error) (int, *responseBody, error) {
+	p.deadline.resume()
+	defer p.deadline.release()
 
-	client := &http.Client{}
+	body, err := newResponseBody(p.resp)
+	if err != nil {
+		p.resp.Body.Close()
+		return 0, nil, timeoutCause(p.deadline.ctx, err)
+	}
+	count, err := decodeList(body, fn)
+	if err != nil {
+		p.resp.Body.Close()
+		return 0, nil, timeoutCause(p.deadline.ctx, err)
+	}
+	if err := body.Close(); err != nil {
+		return 0, nil, timeoutCause(p.deadline.ctx, err)
+	}
+	return count, body, nil
+}
+
+// fetchPages : Request the pages one after the other, following the Sensu-Continue header, and send
+// the responses as soon as their headers are received. Closes pages when done
+func fetchPages(ctx context.Context, reqURL *url.URL, header map[string]string, filter map[string]string, page PageOptions, size int, pages chan<- responsePage) {
+	defer close(pages)
+
+	reqURLQuery := reqURL.Query()
+	reqURLQuery.Set("limit", strconv.Itoa(size))
+	for key, value := range filter {
+		reqURLQuery.Add(key, value)
+	}
 
-	var resp *http.Response
-	for ok := true; ok; ok = len(resp.Header.Get("Sensu-Continue")) != 0 {
+	for number := 1; ; number++ {
 		// Strange behavior here.
 		// when using encore, spaces are translated to + instead of %20.
 		// Using the replace to force %20 instead
 		reqURL.RawQuery = strings.Replace(reqURLQuery.Encode(), "+", "%20", -1)
-		// Ask for the next batch of data
-		req, err := http.NewRequest(method, reqURL.String(), bytes.NewBuffer([]byte(body)))
+
+		start := time.Now()
+		deadline := newPageDeadline(ctx, page.timeout())
+		resp, err := fetchPage(deadline.ctx, reqURL.String(), header)
+		deadline.pause()
 		if err != nil {
-			return nil, err
+			err = timeoutCause(deadline.ctx, err)
+			deadline.release()
+		}
+		select {
+		case pages <- responsePage{number: number, resp: resp, err: err, latency: time.Since(start), deadline: deadline}:
+		case <-ctx.Done():
+			if resp != nil {
+				resp.Body.Close()
+				deadline.release()
+			}
+			return
+		}
+		if err != nil {
+			return
 		}
 
-		for key, value := range header {
-			req.Header.Add(key, value)
+		next := resp.Header.Get("Sensu-Continue")
+		if len(next) == 0 {
+			return
 		}
+		reqURLQuery.Set("continue", next)
+	}
+}
 
-		// Alway application/json format
-		req.Header.Add("Content-Type", "application/json")
+// fetchPage : Request a page of events, the response body is left to be read
+func fetchPage(ctx context.Context, pageURL string, header map[string]string) (*http.Response, error) {
+	logCtx := log.WithFields(log.Fields{
+		"file":     "sensu/backend.go",
+		"function": "fetchPage",
+	})
+
+	// Ask for the next batch of data
+	req, err := http.NewRequestWithContext(ctx, "GET", pageURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for key, value := range header {
+		req.Header.Add(key, value)
+	}
 
-		resp, err = client.Do(req)
+	// Alway application/json format
+	req.Header.Add("Content-Type", "application/json")
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	resp, err := HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	logCtx.Errorf("Request to backend performed. Code: %d", resp.StatusCode)
+	if resp.StatusCode != 200 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected response from the backend: %s", resp.Status)
+	}
+	return resp, nil
+}
+
+// responseBody : Body of a response, decompressed when the backend gzipped it.
+// Transferred counts the bytes read from the network, Elapsed the time spent waiting for them
+type responseBody struct {
+	Transferred int64
+	Elapsed     time.Duration
+	raw         io.ReadCloser
+	gz          *gzip.Reader
+	reader      io.Reader
+}
+
+// newResponseBody : Read the body of a response. Asking for gzip explicitly disables the transparent
+// decompression of the HTTP client, which would hide the transferred size
+func newResponseBody(resp *http.Response) (*responseBody, error) {
+	body := &responseBody{raw: resp.Body}
+	body.reader = readerFunc(body.readRaw)
+	if resp.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(body.reader)
 		if err != nil {
 			return nil, err
 		}
-		ctx.Errorf("Request to backend performed. Code: %d", resp.StatusCode)
-		if resp.StatusCode != 200 {
-			return nil, err
+		body.gz = gz
+		body.reader = gz
+	}
+	return body, nil
+}
+
+// Close : Read what is left of the body and close it. The gzip checksum is only verified at the end
+// of the stream, and a connection is only reused once its response has been read entirely
+func (b *responseBody) Close() error {
+	_, err := io.Copy(io.Discard, b.reader)
+	if b.gz != nil {
+		if gzErr := b.gz.Close(); err == nil {
+			err = gzErr
 		}
+	}
+	if closeErr := b.raw.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
 
-		// Successfull auth
-		// extracting the token
-		token, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			// fmt.Println("Error with the API Request")
-			return nil, err
+func (b *responseBody) readRaw(p []byte) (int, error) {
+	start := time.Now()
+	n, err := b.raw.Read(p)
+	b.Elapsed += time.Since(start)
+	b.Transferred += int64(n)
+	return n, err
+}
+
+// Read : Read the decompressed body
+func (b *responseBody) Read(p []byte) (int, error) {
+	return b.reader.Read(p)
+}
+
+// readerFunc : Adapter allowing a function to be used as an io.Reader
+type readerFunc func(p []byte) (int, error)
+
+func (f readerFunc) Read(p []byte) (int, error) {
+	return f(p)
+}
+
+// decodeList : Decode a JSON array one object at a time, handing each one to fn
+func decodeList[T any](r io.Reader, fn func(obj T) error) (int, error) {
+	dec := json.NewDecoder(r)
+
+	token, err := dec.Token()
+	if err != nil {
+		return 0, err
+	}
+	if token == nil {
+		// null, empty list
+		return 0, nil
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '[' {
+		return 0, fmt.Errorf("expected an array, got %v", token)
+	}
+
+	count := 0
+	for dec.More() {
+		var obj T
+		if err := dec.Decode(&obj); err != nil {
+			return count, err
 		}
-		ctx.Errorf("Reading %d bytes(s) in response body", len(token))
-		ctx.Debugf("buf: %s", string(token))
-		events, err := ExtractEvents(token)
-		if err != nil {
-			return nil, err
+		if err := fn(obj); err != nil {
+			return count, err
 		}
-		eventResults = append(eventResults, events...)
-
-		reqURLQuery.Set("continue", resp.Header.Get("Sensu-Continue"))
+		count++
 	}
 
-	ctx.Debugf("Total Reading %d events", len(eventResults))
+	// Closing bracket
+	_, err = dec.Token()
+	return count, err
+}
 
-	return eventResults, nil
+// StreamEntitiesStatus : Get the entities status from the backend, aggregating the events as they
+// are decoded instead of collecting them first. As GetEntitiesStatus, it expects the events of a
+// single namespace
+func StreamEntitiesStatus(ctx context.Context, rawURL string, header map[string]string, filter map[string]string, page PageOptions) (map[string]EntityStatus, error) {
+	agg := NewAggregator()
+	err := StreamEvents(ctx, rawURL, header, filter, page, func(evt v2.Event) error {
+		agg.Add(evt)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return agg.snapshot(anyNamespace), nil
 }
 
 // EventExtractJSONWithKey : Extract events from API with an APIKey
-func EventExtractJSONWithKey(url string, apikey string, filter map[string]string) ([]v2.Event, error) {
+func EventExtractJSONWithKey(url string, apikey string, filter map[string]string, page PageOptions) ([]v2.Event, error) {
 	ctx := log.WithFields(log.Fields{
 		"file":     "sensu/backend.go",
 		"function": "EventExtractJSONWithKey",
@@ -129,7 +442,7 @@ func EventExtractJSONWithKey(url string, apikey string, filter map[string]string
 	header := map[string]string{
 		"Authorization": "Key " + apikey,
 	}
-	eventResults, err := EventExtractJSONWithHeader(url, header, filter)
+	eventResults, err := EventExtractJSONWithHeader(context.Background(), url, header, filter, page)
 	if err != nil {
 		return nil, err
 	}
@@ -139,7 +452,7 @@ func EventExtractJSONWithKey(url string, apikey string, filter map[string]string
 }
 
 // EventExtractJSONWithUser : Extract event using a login/password
-func EventExtractJSONWithUser(url string, namespace string, user string, password string, filter map[string]string) ([]v2.Event, error) {
+func EventExtractJSONWithUser(url string, namespace string, user string, password string, filter map[string]string, page PageOptions) ([]v2.Event, error) {
 	ctx := log.WithFields(log.Fields{
 		"file":     "sensu/backend.go",
 		"function": "EventExtractJSONWithUser",
@@ -158,7 +471,7 @@ func EventExtractJSONWithUser(url string, namespace string, user string, passwor
 	header := map[string]string{
 		"Authorization": "Bearer " + bearerKey,
 	}
-	eventResults, err := EventExtractJSONWithHeader(eventURL, header, filter)
+	eventResults, err := EventExtractJSONWithHeader(context.Background(), eventURL, header, filter, page)
 	if err != nil {
 		return nil, err
 	}
@@ -175,10 +488,12 @@ func LoginUserPassword(user string, password string, sensuURL string) (string, e
 		"function": "EventExtractJSONWithKey",
 	})
 
-	client := &http.Client{}
+	reqCtx, cancel := withTimeout(context.Background(), DefaultTimeout)
+	defer cancel()
+
 	uriAuth := sensuURL + "/auth"
 	ctx.Debugf("Auth URL: %s", uriAuth)
-	req, err := http.NewRequest("GET", uriAuth, nil)
+	req, err := http.NewRequestWithContext(reqCtx, "GET", uriAuth, nil)
 	if err != nil {
 		return "", err
 	}
@@ -187,9 +502,9 @@ func LoginUserPassword(user string, password string, sensuURL string) (string, e
 	auth := b64.StdEncoding.EncodeToString([]byte(user + ":" + password))
 	req.Header.Add("Authorization", "Basic "+auth)
 
-	resp, err := client.Do(req)
+	resp, err := HTTPClient.Do(req)
 	if err != nil {
-		return "", err
+		return "", timeoutCause(reqCtx, err)
 	}
 	ctx.Debugf("Auth request to backend performed. Code: %d", resp.StatusCode)
 	if resp.StatusCode != 200 {
@@ -200,7 +515,7 @@ func LoginUserPassword(user string, password string, sensuURL string) (string, e
 	// extracting the token
 	token, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return "", err
+		return "", timeoutCause(reqCtx, err)
 	}
 	ctx.Debugf("Reading %d bytes(s) in response body", len(token))
 
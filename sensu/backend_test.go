@@ -0,0 +1,382 @@
+package sensu
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	corev2 "github.com/sensu/core/v2"
+	"github.com/sensu/sensu-plugin-sdk/sensu"
+	"github.com/stretchr/testify/assert"
+)
+
+// newEventsServer : Serve the events API, paginated as the backend does with the limit and continue
+// parameters. Every entity has a single check, critical for one entity out of ten
+func newEventsServer(tb testing.TB, entities int, outputSize int) *httptest.Server {
+	tb.Helper()
+
+	var events []json.RawMessage
+	for i := 0; i < entities; i++ {
+		evt := corev2.FixtureEvent(fmt.Sprintf("entity%d", i), "dummy-check")
+		if i%10 == 0 {
+			evt.Check.Status = sensu.CheckStateCritical
+		}
+		evt.Check.Output = strings.Repeat("x", outputSize)
+		raw, err := json.Marshal(evt)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		events = append(events, raw)
+	}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		start, _ := strconv.Atoi(r.URL.Query().Get("continue"))
+		end := start + limit
+		if limit <= 0 || end >= len(events) {
+			end = len(events)
+		} else {
+			w.Header().Set("Sensu-Continue", strconv.Itoa(end))
+		}
+
+		fmt.Fprint(w, "[")
+		for i, raw := range events[start:end] {
+			if i > 0 {
+				fmt.Fprint(w, ",")
+			}
+			w.Write(raw)
+		}
+		fmt.Fprint(w, "]")
+	}))
+}
+
+func TestEventExtractJSONWithHeader(t *testing.T) {
+	assert := assert.New(t)
+
+	server := newEventsServer(t, 450, 10)
+	defer server.Close()
+
+	events, err := EventExtractJSONWithHeader(context.Background(), server.URL, nil, nil, PageOptions{})
+	assert.NoError(err)
+	assert.Len(events, 450)
+	assert.Equal("entity449", events[449].Entity.Name)
+}
+
+func TestStreamEntitiesStatus(t *testing.T) {
+	assert := assert.New(t)
+
+	server := newEventsServer(t, 450, 10)
+	defer server.Close()
+
+	statuses, err := StreamEntitiesStatus(context.Background(), server.URL, nil, nil, PageOptions{})
+	assert.NoError(err)
+	assert.Len(statuses, 450)
+	assert.Equal(45, GetStatusSummary(statuses).Critical)
+
+	events, err := EventExtractJSONWithHeader(context.Background(), server.URL, nil, nil, PageOptions{})
+	assert.NoError(err)
+	assert.Equal(GetEntitiesStatus(events), statuses)
+}
+
+func TestStreamEventsErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/forbidden" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		fmt.Fprint(w, `{"message": "not a list"}`)
+	}))
+	defer server.Close()
+
+	_, err := EventExtractJSONWithHeader(context.Background(), server.URL+"/forbidden", nil, nil, PageOptions{})
+	assert.ErrorContains(err, "403")
+
+	_, err = StreamEntitiesStatus(context.Background(), server.URL, nil, nil, PageOptions{})
+	assert.Error(err)
+}
+
+// heapProbe : Highest live heap observed at the probe points, above the heap in use when the probe was created
+type heapProbe struct {
+	base uint64
+	peak uint64
+}
+
+func newHeapProbe() *heapProbe {
+	p := &heapProbe{}
+	p.base = p.live()
+	return p
+}
+
+func (p *heapProbe) live() uint64 {
+	var stats runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&stats)
+	return stats.HeapAlloc
+}
+
+func (p *heapProbe) sample() {
+	if live := p.live(); live > p.base && live-p.base > p.peak {
+		p.peak = live - p.base
+	}
+}
+
+// extractPages : Collect the events as EventExtractJSONWithHeader used to, reading every page
+// body entirely before unmarshalling it. The probe is sampled at every page
+func extractPages(rawURL string, probe *heapProbe) ([]corev2.Event, error) {
+	events := []corev2.Event{}
+	query := url.Values{"limit": {strconv.Itoa(DefaultPageSize)}}
+	for {
+		resp, err := http.Get(rawURL + "?" + query.Encode())
+		if err != nil {
+			return nil, err
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		page, err := ExtractEvents(body)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, page...)
+		probe.sample()
+		runtime.KeepAlive(body)
+
+		next := resp.Header.Get("Sensu-Continue")
+		if len(next) == 0 {
+			return events, nil
+		}
+		query.Set("continue", next)
+	}
+}
+
+// Compare the peak memory of the entities status computed from the whole list of events, read a page
+// at a time, and from the stream of events: go test -run XXX -bench EntitiesStatus ./sensu/
+func BenchmarkEntitiesStatusSlice(b *testing.B) {
+	server := newEventsServer(b, 5000, 2048)
+	defer server.Close()
+
+	b.ReportAllocs()
+	var peak uint64
+	for i := 0; i < b.N; i++ {
+		probe := newHeapProbe()
+		events, err := extractPages(server.URL, probe)
+		if err != nil {
+			b.Fatal(err)
+		}
+		// Every event is still in memory when aggregating
+		statuses := GetEntitiesStatus(events)
+		probe.sample()
+		runtime.KeepAlive(events)
+		runtime.KeepAlive(statuses)
+		peak = max(peak, probe.peak)
+	}
+	b.ReportMetric(float64(peak), "peak-heap-B")
+}
+
+func BenchmarkEntitiesStatusStream(b *testing.B) {
+	server := newEventsServer(b, 5000, 2048)
+	defer server.Close()
+
+	b.ReportAllocs()
+	var peak uint64
+	for i := 0; i < b.N; i++ {
+		probe := newHeapProbe()
+		agg := NewAggregator()
+		count := 0
+		err := StreamEvents(context.Background(), server.URL, nil, nil, PageOptions{}, func(evt corev2.Event) error {
+			agg.Add(evt)
+			if count++; count%500 == 0 {
+				probe.sample()
+			}
+			return nil
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+		statuses := agg.Snapshot("default")
+		probe.sample()
+		runtime.KeepAlive(statuses)
+		peak = max(peak, probe.peak)
+	}
+	b.ReportMetric(float64(peak), "peak-heap-B")
+}
+
+func TestStreamEventsPageSize(t *testing.T) {
+	assert := assert.New(t)
+
+	server := newEventsServer(t, 450, 10)
+	defer server.Close()
+
+	for _, page := range []PageOptions{{Size: 100}, {Size: 1000}, {Size: 50, Prefetch: 4}} {
+		names := []string{}
+		err := StreamEvents(context.Background(), server.URL, nil, nil, page, func(evt corev2.Event) error {
+			names = append(names, evt.Entity.Name)
+			return nil
+		})
+		assert.NoError(err)
+		assert.Len(names, 450)
+		// Pages are decoded in order
+		assert.Equal("entity0", names[0])
+		assert.Equal("entity449", names[449])
+	}
+}
+
+func TestStreamEventsStop(t *testing.T) {
+	assert := assert.New(t)
+
+	server := newEventsServer(t, 450, 10)
+	defer server.Close()
+
+	// An error from fn stops the pagination
+	count := 0
+	stop := fmt.Errorf("stop")
+	err := StreamEvents(context.Background(), server.URL, nil, nil, PageOptions{Size: 10, Prefetch: 2}, func(evt corev2.Event) error {
+		if count++; count == 25 {
+			return stop
+		}
+		return nil
+	})
+	assert.ErrorIs(err, stop)
+	assert.Equal(25, count)
+
+	// So does a failing page
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("continue") == "20" {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		server.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer failing.Close()
+
+	events, err := EventExtractJSONWithHeader(context.Background(), failing.URL, nil, nil, PageOptions{Size: 10})
+	assert.ErrorContains(err, "503")
+	assert.Nil(events)
+
+	// And cancelling the context
+	cancelCtx, cancel := context.WithCancel(context.Background())
+	count = 0
+	err = StreamEvents(cancelCtx, server.URL, nil, nil, PageOptions{Size: 10}, func(evt corev2.Event) error {
+		if count++; count == 25 {
+			cancel()
+		}
+		return nil
+	})
+	assert.ErrorIs(err, context.Canceled)
+	assert.Less(count, 450)
+}
+
+func TestStreamEventsGzip(t *testing.T) {
+	assert := assert.New(t)
+
+	events := newEventsServer(t, 50, 1000)
+	defer events.Close()
+
+	corrupt := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept-Encoding") != "gzip" {
+			events.Config.Handler.ServeHTTP(w, r)
+			return
+		}
+		rec := httptest.NewRecorder()
+		events.Config.Handler.ServeHTTP(rec, r)
+		for key, values := range rec.Header() {
+			w.Header()[key] = values
+		}
+		var body bytes.Buffer
+		gz := gzip.NewWriter(&body)
+		gz.Write(rec.Body.Bytes())
+		gz.Close()
+		if corrupt {
+			// Checksum in the trailer of the gzip stream
+			body.Bytes()[body.Len()-8] ^= 0xff
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write(body.Bytes())
+	}))
+	defer server.Close()
+
+	statuses, err := StreamEntitiesStatus(context.Background(), server.URL, nil, nil, PageOptions{Size: 20})
+	assert.NoError(err)
+	assert.Len(statuses, 50)
+
+	// The stream is read past the end of the list, up to its checksum
+	corrupt = true
+	_, err = StreamEntitiesStatus(context.Background(), server.URL, nil, nil, PageOptions{Size: 20})
+	assert.ErrorIs(err, gzip.ErrChecksum)
+}
+
+func TestResponseBodyElapsed(t *testing.T) {
+	assert := assert.New(t)
+
+	// The network is slow, the time spent waiting for the body is not part of the decoding
+	slow := readerFunc(func(p []byte) (int, error) {
+		time.Sleep(10 * time.Millisecond)
+		return strings.NewReader("[]").Read(p)
+	})
+	body, err := newResponseBody(&http.Response{Header: http.Header{}, Body: io.NopCloser(io.LimitReader(slow, 2))})
+	assert.NoError(err)
+
+	count, err := decodeList(body, func(evt corev2.Event) error { return nil })
+	assert.NoError(err)
+	assert.Equal(0, count)
+	assert.GreaterOrEqual(body.Elapsed, 10*time.Millisecond)
+	assert.Equal(int64(2), body.Transferred)
+}
+
+func TestStreamEventsTimeout(t *testing.T) {
+	assert := assert.New(t)
+
+	events := newEventsServer(t, 50, 10)
+	defer events.Close()
+
+	// Pages fetched ahead wait for the previous ones to be decoded, longer than the timeout
+	count := 0
+	err := StreamEvents(context.Background(), events.URL, nil, nil, PageOptions{Size: 10, Prefetch: 4, Timeout: 200 * time.Millisecond}, func(evt corev2.Event) error {
+		time.Sleep(10 * time.Millisecond)
+		count++
+		return nil
+	})
+	assert.NoError(err)
+	assert.Equal(50, count)
+
+	// The wait for the headers and the read of the body are limited
+	release := make(chan struct{})
+	defer close(release)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/body" {
+			fmt.Fprint(w, "[")
+			w.(http.Flusher).Flush()
+		}
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+
+	for _, path := range []string{"/headers", "/body"} {
+		start := time.Now()
+		err = StreamEvents(context.Background(), server.URL+path, nil, nil, PageOptions{Timeout: 50 * time.Millisecond}, func(evt corev2.Event) error {
+			return nil
+		})
+		assert.ErrorIs(err, errTimeout, path)
+		assert.ErrorContains(err, "after 50ms", path)
+		assert.Less(time.Since(start), 5*time.Second, path)
+	}
+}
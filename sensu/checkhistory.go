@@ -0,0 +1,76 @@
+package sensu
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	v2 "github.com/sensu/core/v2"
+	"github.com/sensu/sensu-plugin-sdk/sensu"
+)
+
+// Rollup : Report a check as failing when it failed in at least Failing of its last Executions executions,
+// instead of only looking at its latest status. The zero value disables the rollup
+type Rollup struct {
+	Failing    int
+	Executions int
+}
+
+// ParseRollup : Parse a rollup given as N/M, failing in N of the last M executions.
+// An empty string disables the rollup
+func ParseRollup(value string) (Rollup, error) {
+	if len(value) == 0 {
+		return Rollup{}, nil
+	}
+
+	failing, executions, ok := strings.Cut(value, "/")
+	n, errN := strconv.Atoi(strings.TrimSpace(failing))
+	m, errM := strconv.Atoi(strings.TrimSpace(executions))
+	if !ok || errN != nil || errM != nil || n <= 0 || m < n {
+		return Rollup{}, fmt.Errorf("invalid rollup %q, must be N/M with 0 < N <= M", value)
+	}
+	return Rollup{Failing: n, Executions: m}, nil
+}
+
+// ApplyRollup : Copy the events, rolling up the status of their checks. A check failing in at least
+// Failing of its last Executions executions gets the status of its most recent failure, other checks are OK.
+// The events are returned unchanged when the rollup is disabled
+func ApplyRollup(events []v2.Event, rollup Rollup) []v2.Event {
+	if rollup.Executions <= 0 {
+		return events
+	}
+
+	rolled := make([]v2.Event, 0, len(events))
+	for _, evt := range events {
+		rolled = append(rolled, RollupEvent(evt, rollup))
+	}
+	return rolled
+}
+
+// RollupEvent : Copy an event, rolling up the status of its check, see ApplyRollup
+func RollupEvent(evt v2.Event, rollup Rollup) v2.Event {
+	if rollup.Executions <= 0 || evt.Check == nil {
+		return evt
+	}
+
+	samples := checkSamples(evt.Check)
+	if len(samples) > rollup.Executions {
+		samples = samples[len(samples)-rollup.Executions:]
+	}
+	failed := 0
+	status := sensu.CheckStateOK
+	for _, sample := range samples {
+		if sample.Status != sensu.CheckStateOK {
+			failed++
+			status = sample.Status
+		}
+	}
+	if failed < rollup.Failing {
+		status = sensu.CheckStateOK
+	}
+
+	check := *evt.Check
+	check.Status = uint32(status)
+	evt.Check = &check
+	return evt
+}
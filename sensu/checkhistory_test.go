@@ -0,0 +1,99 @@
+package sensu
+
+import (
+	"testing"
+
+	corev2 "github.com/sensu/core/v2"
+	"github.com/sensu/sensu-plugin-sdk/sensu"
+	"github.com/stretchr/testify/assert"
+)
+
+// historyEvent : Event whose check executed with the given statuses, oldest first, the last one being its current status
+func historyEvent(entity string, check string, statuses ...uint32) corev2.Event {
+	evt := *corev2.FixtureEvent(entity, check)
+	evt.Check.History = nil
+	for i, status := range statuses {
+		evt.Check.History = append(evt.Check.History, corev2.CheckHistory{Status: status, Executed: int64(100 + 10*i)})
+	}
+	evt.Check.Executed = int64(100 + 10*(len(statuses)-1))
+	evt.Check.Status = statuses[len(statuses)-1]
+	return evt
+}
+
+func TestGetEntitiesStatusCheckHistory(t *testing.T) {
+	assert := assert.New(t)
+
+	silenced := historyEvent("localhost3", "dummy-check2", 2, 2, 2, 2)
+	silenced.Check.Silenced = []string{"test"}
+	occurrences := historyEvent("localhost4", "dummy-check1", 0, 1, 1)
+	occurrences.Check.Occurrences = 30
+
+	statuses := GetEntitiesStatus([]corev2.Event{
+		historyEvent("localhost", "dummy-check1", 0, 2, 0, 2, 2),
+		historyEvent("localhost", "dummy-check2", 0, 0, 0, 0, 1),
+		historyEvent("localhost2", "dummy-check1", 0, 2, 2, 0, 0),
+		historyEvent("localhost3", "dummy-check1", 0, 0, 0, 0),
+		silenced,
+		occurrences,
+	})
+
+	// 4 failures out of 10 executions, dummy-check1 failing for 2 executions
+	assert.InDelta(0.4, statuses["localhost"].FailureRatio, 0.0001)
+	assert.Equal(2, statuses["localhost"].FailingStreak)
+	assert.False(statuses["localhost"].Recovered)
+
+	assert.InDelta(0.4, statuses["localhost2"].FailureRatio, 0.0001)
+	assert.Equal(0, statuses["localhost2"].FailingStreak)
+	assert.True(statuses["localhost2"].Recovered)
+
+	// Silenced checks are left out
+	assert.Equal(0.0, statuses["localhost3"].FailureRatio)
+	assert.Equal(0, statuses["localhost3"].FailingStreak)
+	assert.False(statuses["localhost3"].Recovered)
+
+	// Occurrences outlast the history
+	assert.Equal(30, statuses["localhost4"].FailingStreak)
+
+	single := GetEntityStatus("localhost2", []corev2.Event{historyEvent("localhost2", "dummy-check1", 0, 2, 2, 0, 0)})
+	assert.True(single.Recovered)
+}
+
+func TestParseRollup(t *testing.T) {
+	assert := assert.New(t)
+
+	rollup, err := ParseRollup("3/5")
+	assert.NoError(err)
+	assert.Equal(Rollup{Failing: 3, Executions: 5}, rollup)
+
+	rollup, err = ParseRollup("")
+	assert.NoError(err)
+	assert.Equal(Rollup{}, rollup)
+
+	for _, invalid := range []string{"3", "a/5", "0/5", "6/5", "3/"} {
+		_, err := ParseRollup(invalid)
+		assert.Error(err, invalid)
+	}
+}
+
+func TestApplyRollup(t *testing.T) {
+	assert := assert.New(t)
+
+	events := []corev2.Event{
+		// Failed in 3 of the last 5 executions, currently OK
+		historyEvent("localhost", "dummy-check1", 2, 2, 0, 1, 2, 0),
+		// A single recent failure
+		historyEvent("localhost", "dummy-check2", 0, 0, 0, 0, 2),
+	}
+
+	rolled := ApplyRollup(events, Rollup{Failing: 3, Executions: 5})
+	assert.Len(rolled, 2)
+	assert.Equal(uint32(sensu.CheckStateCritical), rolled[0].Check.Status)
+	assert.Equal(uint32(sensu.CheckStateOK), rolled[1].Check.Status)
+
+	// The original events are left untouched
+	assert.Equal(uint32(sensu.CheckStateOK), events[0].Check.Status)
+	assert.Equal(uint32(sensu.CheckStateCritical), events[1].Check.Status)
+
+	// Disabled rollup
+	assert.Equal(events, ApplyRollup(events, Rollup{}))
+}
@@ -0,0 +1,87 @@
+package sensu
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+
+	"github.com/apex/log"
+)
+
+// DelimitedOptions : Options of the CSV and TSV outputs
+type DelimitedOptions struct {
+	// Comma is the field delimiter
+	Comma rune
+	// Namespace is written in the namespace column of every row
+	Namespace string
+	// Labels are the entity labels added as extra columns, in the given order
+	Labels []string
+	// EntityLabels holds the labels of every entity, as returned by GetEntitiesLabels
+	EntityLabels map[string]map[string]string
+	// NoHeaders disables the header row
+	NoHeaders bool
+}
+
+// DelimitedColumns : Columns of the CSV and TSV outputs, before the label columns
+var DelimitedColumns = []string{"entity", "namespace", "status", "silenced", "critical", "warning", "unknown", "ok", "total"}
+
+// WriteDelimitedResult : Write the entities status as delimiter separated values, one line per entity.
+// Comma separated values follow RFC 4180, tab separated values end their lines with LF
+func WriteDelimitedResult(w io.Writer, statusMap map[string]EntityStatus, opts DelimitedOptions) error {
+	ctx := log.WithFields(log.Fields{
+		"file":     "sensu/csv.go",
+		"function": "WriteDelimitedResult",
+	})
+
+	cw := csv.NewWriter(w)
+	if opts.Comma != 0 {
+		cw.Comma = opts.Comma
+	}
+	cw.UseCRLF = cw.Comma == ','
+
+	if !opts.NoHeaders {
+		header := append([]string{}, DelimitedColumns...)
+		for _, label := range opts.Labels {
+			header = append(header, "label:"+label)
+		}
+		if err := cw.Write(header); err != nil {
+			return err
+		}
+	}
+
+	ctx.Debugf("Writing %d rows", len(statusMap))
+	for _, entity := range sortedEntities(statusMap) {
+		status := statusMap[entity]
+		record := []string{entity, opts.Namespace}
+		for _, field := range statusFields(status) {
+			record = append(record, strconv.Itoa(field.Value))
+		}
+		for _, label := range opts.Labels {
+			record = append(record, opts.EntityLabels[entity][label])
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// DelimitedFormatter : Delimiter separated values output of the diff and report subcommands
+type DelimitedFormatter struct {
+	// Comma is the field delimiter
+	Comma rune
+	// NoHeaders disables the header row
+	NoHeaders bool
+}
+
+// FormatDiff : Write the difference between two snapshots as delimiter separated values
+func (f DelimitedFormatter) FormatDiff(w io.Writer, diff SnapshotDiff) error {
+	return WriteDiffDelimited(w, diff, f.Comma, f.NoHeaders)
+}
+
+// FormatReport : Write the availability report as delimiter separated values
+func (f DelimitedFormatter) FormatReport(w io.Writer, report AvailabilityReport) error {
+	return WriteReportDelimited(w, report, f.Comma, f.NoHeaders)
+}
@@ -0,0 +1,60 @@
+package sensu
+
+import (
+	"bytes"
+	"testing"
+
+	corev2 "github.com/sensu/core/v2"
+	"github.com/sensu/sensu-plugin-sdk/sensu"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteDelimitedResult(t *testing.T) {
+	assert := assert.New(t)
+
+	statusMap := map[string]EntityStatus{
+		"web,1":     {Status: sensu.CheckStateCritical, Critical: 1, Ok: 2, Total: 3},
+		"localhost": {Status: sensu.CheckStateOK, Ok: 1, Total: 1},
+	}
+	opts := DelimitedOptions{
+		Comma:     ',',
+		Namespace: "default",
+		Labels:    []string{"team"},
+		EntityLabels: map[string]map[string]string{
+			"web,1": {"team": `web "front"`},
+		},
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(WriteDelimitedResult(&buf, statusMap, opts))
+	assert.Equal(
+		"entity,namespace,status,silenced,critical,warning,unknown,ok,total,label:team\r\n"+
+			"localhost,default,0,0,0,0,0,1,1,\r\n"+
+			"\"web,1\",default,2,0,1,0,0,2,3,\"web \"\"front\"\"\"\r\n",
+		buf.String(),
+	)
+
+	opts.Comma = '\t'
+	opts.Labels = nil
+	opts.NoHeaders = true
+	buf.Reset()
+	assert.NoError(WriteDelimitedResult(&buf, statusMap, opts))
+	assert.Equal(
+		"localhost\tdefault\t0\t0\t0\t0\t0\t1\t1\n"+
+			"web,1\tdefault\t2\t0\t1\t0\t0\t2\t3\n",
+		buf.String(),
+	)
+}
+
+func TestGetEntitiesLabels(t *testing.T) {
+	assert := assert.New(t)
+
+	evt1 := *corev2.FixtureEvent("localhost", "dummy-check1")
+	evt2 := *corev2.FixtureEvent("localhost2", "dummy-check1")
+	evt1.Entity.ObjectMeta.Labels = map[string]string{"team": "web"}
+
+	labels := GetEntitiesLabels([]corev2.Event{evt1, evt2})
+	assert.Len(labels, 2)
+	assert.Equal("web", labels["localhost"]["team"])
+	assert.Empty(labels["localhost2"]["team"])
+}
@@ -0,0 +1,261 @@
+package sensu
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/sensu/sensu-plugin-sdk/sensu"
+)
+
+// WrappedChangeType : Type of the entity changes in sensuctl envelopes
+const WrappedChangeType = "EntityStatusChange"
+
+// changeKinds : Kinds of change, in output order
+var changeKinds = []string{ChangeAppeared, ChangeDisappeared, ChangeWorse, ChangeRecovered}
+
+// DiffExitStatuser : DiffFormatter whose output comes with a process exit status, as Nagios plugins do
+type DiffExitStatuser interface {
+	DiffExitStatus(diff SnapshotDiff) int
+}
+
+// countChanges : Number of entities of each kind of change
+func countChanges(diff SnapshotDiff) map[string]int {
+	counts := make(map[string]int, len(changeKinds))
+	for _, kind := range changeKinds {
+		counts[kind] = 0
+	}
+	for _, change := range diff.Changes {
+		counts[change.Change]++
+	}
+	return counts
+}
+
+// WriteDiffWrappedJSON : Write the difference between two snapshots as a stream of sensuctl
+// wrapped-json resources, one per changed entity
+func WriteDiffWrappedJSON(w io.Writer, diff SnapshotDiff) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+
+	for _, change := range diff.Changes {
+		resource := struct {
+			Type       string          `json:"type"`
+			APIVersion string          `json:"api_version"`
+			Metadata   WrappedMetadata `json:"metadata"`
+			Spec       EntityChange    `json:"spec"`
+		}{
+			Type:       WrappedChangeType,
+			APIVersion: WrappedAPIVersion,
+			Metadata:   WrappedMetadata{Name: change.Name, Namespace: diff.To.Namespace},
+			Spec:       change,
+		}
+		if err := enc.Encode(resource); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+var htmlDiffTemplate = template.Must(template.New("diff").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Entities status changes - {{.To.Namespace}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.8em; text-align: left; }
+.appeared, .recovered { background: #dff0d8; }
+.worse { background: #f2dede; }
+.disappeared { background: #e8e8e8; }
+</style>
+</head>
+<body>
+<h1>Entities status changes - {{.To.Namespace}}</h1>
+<p>From {{.From.Generated.Format "2006-01-02 15:04:05 MST"}} to {{.To.Generated.Format "2006-01-02 15:04:05 MST"}}</p>
+{{if .Rows}}<table>
+<tr><th>Change</th><th>Entity</th><th>Before</th><th>After</th></tr>
+{{range .Rows}}<tr class="{{.Change}}"><td>{{.Change}}</td><td>{{.Name}}</td><td>{{.Before}}</td><td>{{.After}}</td></tr>
+{{end}}</table>{{else}}<p>No change</p>{{end}}
+</body>
+</html>
+`))
+
+// WriteDiffHTML : Write the difference between two snapshots as a standalone HTML page
+func WriteDiffHTML(w io.Writer, diff SnapshotDiff) error {
+	type row struct {
+		Name   string
+		Change string
+		Before string
+		After  string
+	}
+
+	data := struct {
+		From TemplateMetadata
+		To   TemplateMetadata
+		Rows []row
+	}{From: diff.From, To: diff.To}
+	for _, change := range diff.Changes {
+		data.Rows = append(data.Rows, row{
+			Name:   change.Name,
+			Change: change.Change,
+			Before: changeStatus(change.Before),
+			After:  changeStatus(change.After),
+		})
+	}
+
+	return htmlDiffTemplate.Execute(w, data)
+}
+
+// WriteDiffJUnit : Write the difference between two snapshots as a JUnit XML report.
+// Every changed entity is a testcase, entities that got worse are failures
+func WriteDiffJUnit(w io.Writer, diff SnapshotDiff) error {
+	suite := junitTestSuite{
+		Name:      "changes",
+		Timestamp: diff.To.Generated.UTC().Format(time.RFC3339),
+	}
+	for _, change := range diff.Changes {
+		testCase := junitTestCase{
+			Name:      change.Change,
+			ClassName: diff.To.Namespace + "." + change.Name,
+			Time:      "0.000",
+		}
+		message := fmt.Sprintf("%s: %s -> %s", change.Name, changeStatus(change.Before), changeStatus(change.After))
+		if change.Change == ChangeWorse {
+			testCase.Failure = &junitFailure{Message: message, Type: changeStatus(change.After)}
+			suite.Failures++
+		} else {
+			testCase.SystemOut = message
+		}
+		suite.Tests++
+		suite.Cases = append(suite.Cases, testCase)
+	}
+
+	report := junitTestSuites{
+		Name:     "entities-status diff " + diff.To.Namespace,
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Suites:   []junitTestSuite{suite},
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// GetDiffStatus : Worst status of the entities that appeared or got worse, OK when none did
+func GetDiffStatus(diff SnapshotDiff) int {
+	status := sensu.CheckStateOK
+	for _, change := range diff.Changes {
+		if change.Change == ChangeWorse || change.Change == ChangeAppeared {
+			status = calculateStatus(status, change.After.Status)
+		}
+	}
+	return status
+}
+
+// WriteDiffNagios : Write the difference between two snapshots as a Nagios plugin output.
+// The state is the worst status of the entities that appeared or got worse, followed by one line per change
+func WriteDiffNagios(w io.Writer, diff SnapshotDiff) error {
+	counts := countChanges(diff)
+
+	var summary, perfdata []string
+	for _, kind := range changeKinds {
+		summary = append(summary, fmt.Sprintf("%d %s", counts[kind], kind))
+		perfdata = append(perfdata, fmt.Sprintf("%s=%d;;;0", kind, counts[kind]))
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "ENTITIES CHANGES %s - %s | %s\n",
+		nagiosStateNames[GetDiffStatus(diff)],
+		strings.Join(summary, ", "),
+		strings.Join(perfdata, " "),
+	)
+	for _, change := range diff.Changes {
+		fmt.Fprintf(bw, "%s %s: %s -> %s\n", change.Change, change.Name, changeStatus(change.Before), changeStatus(change.After))
+	}
+	return bw.Flush()
+}
+
+// nagiosDiffFormatter : Nagios plugin output of a snapshot difference, exiting with GetDiffStatus
+type nagiosDiffFormatter struct{}
+
+// FormatDiff : Write the difference between two snapshots as a Nagios plugin output
+func (nagiosDiffFormatter) FormatDiff(w io.Writer, diff SnapshotDiff) error {
+	return WriteDiffNagios(w, diff)
+}
+
+// DiffExitStatus : Worst status of the entities that appeared or got worse
+func (nagiosDiffFormatter) DiffExitStatus(diff SnapshotDiff) int {
+	return GetDiffStatus(diff)
+}
+
+// WriteDiffPrometheus : Write the difference between two snapshots in Prometheus text exposition
+// format, the number of entities of each kind of change and the change of every entity
+func WriteDiffPrometheus(w io.Writer, diff SnapshotDiff) error {
+	counts := countChanges(diff)
+	ns := prometheusLabelEscaper.Replace(diff.To.Namespace)
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "# HELP sensu_entities_changes Number of entities by kind of status change between the snapshots")
+	fmt.Fprintln(bw, "# TYPE sensu_entities_changes gauge")
+	for _, kind := range changeKinds {
+		fmt.Fprintf(bw, "sensu_entities_changes{namespace=\"%s\",change=\"%s\"} %d\n", ns, kind, counts[kind])
+	}
+
+	fmt.Fprintln(bw, "# HELP sensu_entity_change Status change of the entity between the snapshots")
+	fmt.Fprintln(bw, "# TYPE sensu_entity_change gauge")
+	for _, change := range diff.Changes {
+		fmt.Fprintf(bw, "sensu_entity_change{namespace=\"%s\",entity=\"%s\",change=\"%s\",before=\"%s\",after=\"%s\"} 1\n",
+			ns,
+			prometheusLabelEscaper.Replace(change.Name),
+			change.Change,
+			changeStatus(change.Before),
+			changeStatus(change.After),
+		)
+	}
+	return bw.Flush()
+}
+
+// WriteDiffInflux : Write the number of entities of each kind of change in InfluxDB line protocol,
+// as a sensu_entities_changes point tagged with namespace and cluster when set
+func WriteDiffInflux(w io.Writer, diff SnapshotDiff) error {
+	counts := countChanges(diff)
+
+	var fields []string
+	for _, kind := range changeKinds {
+		fields = append(fields, fmt.Sprintf("%s=%di", kind, counts[kind]))
+	}
+
+	_, err := fmt.Fprintf(w, "sensu_entities_changes%s %s %d\n",
+		influxTags(diff.To.Namespace, diff.To.Cluster),
+		strings.Join(fields, ","),
+		diff.To.Generated.UnixNano(),
+	)
+	return err
+}
+
+// WriteDiffGraphite : Write the number of entities of each kind of change in Graphite plaintext
+// protocol. Metric paths are sensu.[cluster.][namespace.]changes.kind
+func WriteDiffGraphite(w io.Writer, diff SnapshotDiff) error {
+	counts := countChanges(diff)
+	prefix := graphitePrefix(diff.To.Namespace, diff.To.Cluster)
+
+	bw := bufio.NewWriter(w)
+	for _, kind := range changeKinds {
+		fmt.Fprintf(bw, "%schanges.%s %d %d\n", prefix, kind, counts[kind], diff.To.Generated.Unix())
+	}
+	return bw.Flush()
+}
@@ -1,6 +1,9 @@
 package sensu
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/apex/log"
 	v2 "github.com/sensu/core/v2"
 	"github.com/sensu/sensu-plugin-sdk/sensu"
@@ -15,6 +18,34 @@ type EntityStatus struct {
 	Unknown  int `json:"unknown" yaml:"unknown"`
 	Ok       int `json:"ok" yaml:"ok"`
 	Total    int `json:"total" yaml:"total"`
+	// Since is the time the entity entered its current status and Flaps its number of status
+	// changes, both derived from the history file when one is used
+	Since int64 `json:"since,omitempty" yaml:"since,omitempty"`
+	Flaps int   `json:"flaps,omitempty" yaml:"flaps,omitempty"`
+	// FailureRatio is the share of failed executions in the history of the non silenced checks,
+	// FailingStreak the number of consecutive failures of the longest failing check and Recovered
+	// tells whether an OK entity had failures in that history
+	FailureRatio  float64 `json:"failure_ratio,omitempty" yaml:"failure_ratio,omitempty"`
+	FailingStreak int     `json:"failing_streak,omitempty" yaml:"failing_streak,omitempty"`
+	Recovered     bool    `json:"recovered,omitempty" yaml:"recovered,omitempty"`
+}
+
+// StatusSummary : Structure used to count entities in each status
+type StatusSummary struct {
+	Total    int `json:"total" yaml:"total"`
+	Critical int `json:"critical" yaml:"critical"`
+	Warning  int `json:"warning" yaml:"warning"`
+	Unknown  int `json:"unknown" yaml:"unknown"`
+	Ok       int `json:"ok" yaml:"ok"`
+}
+
+// eventNamespace : Namespace of an event, the one of its entity when the event has none,
+// as events read offline may only carry it in their entity
+func eventNamespace(evt v2.Event) string {
+	if len(evt.Namespace) == 0 && evt.Entity != nil {
+		return evt.Entity.Namespace
+	}
+	return evt.Namespace
 }
 
 // GetEntitiesFromEvents : Get a list of entities based on a list of event
@@ -40,6 +71,76 @@ func GetEntitiesFromEvents(events []v2.Event) []string {
 	return entities
 }
 
+// GetEntitiesLabels : Get the labels of every entity based on a list of event
+func GetEntitiesLabels(events []v2.Event) map[string]map[string]string {
+	labels := make(map[string]map[string]string)
+
+	for _, evt := range events {
+		if evt.Entity == nil {
+			continue
+		}
+		if _, ok := labels[evt.Entity.Name]; !ok {
+			labels[evt.Entity.Name] = evt.Entity.ObjectMeta.Labels
+		}
+	}
+
+	return labels
+}
+
+// EntityInfo : Entity details displayed by the wide tabular output
+type EntityInfo struct {
+	Namespace     string
+	EntityClass   string
+	Subscriptions []string
+	LastSeen      int64
+	// WorstCheck is the name of the most severe, non silenced, failing check. Empty when all checks are OK
+	WorstCheck string
+}
+
+// newEntityInfo : Details of an entity, without its worst check
+func newEntityInfo(entity *v2.Entity) EntityInfo {
+	info := EntityInfo{
+		Namespace:   entity.Namespace,
+		EntityClass: entity.EntityClass,
+		LastSeen:    entity.LastSeen,
+	}
+	for _, subscription := range entity.Subscriptions {
+		// Skip the entity:NAME subscription every entity has
+		if !strings.HasPrefix(subscription, "entity:") {
+			info.Subscriptions = append(info.Subscriptions, subscription)
+		}
+	}
+	return info
+}
+
+// GetEntitiesInfo : Get the details of every entity based on a list of event
+func GetEntitiesInfo(events []v2.Event) map[string]EntityInfo {
+	infos := make(map[string]EntityInfo)
+	worst := make(map[string]int)
+
+	for _, evt := range events {
+		if evt.Entity == nil {
+			continue
+		}
+
+		info, ok := infos[evt.Entity.Name]
+		if !ok {
+			info = newEntityInfo(evt.Entity)
+		}
+
+		if evt.Check != nil && !evt.IsSilenced() && evt.Check.Status != sensu.CheckStateOK {
+			severity := StatusSeverity(int(evt.Check.Status))
+			if severity > worst[evt.Entity.Name] || (severity == worst[evt.Entity.Name] && evt.Check.Name < info.WorstCheck) {
+				worst[evt.Entity.Name] = severity
+				info.WorstCheck = evt.Check.Name
+			}
+		}
+		infos[evt.Entity.Name] = info
+	}
+
+	return infos
+}
+
 // calculateStatus : This function is used to calculate the resulting status when comparing two status
 // The comparison matrix will be the following one:
 //
@@ -85,39 +186,15 @@ func GetEntityStatus(entityName string, events []v2.Event) EntityStatus {
 		"function": "GetEntityStatus",
 	})
 
-	gstatus := EntityStatus{
-		Status:   0,
-		Silenced: 0,
-		Critical: 0,
-		Warning:  0,
-		Unknown:  0,
-		Ok:       0,
-		Total:    0,
-	}
-
+	agg := NewAggregator()
 	for _, evt := range events {
 		if evt.Entity.Name != entityName {
 			// Only look into our entity events
 			continue
 		}
-
-		if evt.IsSilenced() {
-			gstatus.Silenced++
-		}
-		if evt.Check.Status == sensu.CheckStateCritical {
-			gstatus.Critical++
-		} else if evt.Check.Status == sensu.CheckStateWarning {
-			gstatus.Warning++
-		} else if evt.Check.Status == sensu.CheckStateUnknown {
-			gstatus.Unknown++
-		} else {
-			gstatus.Ok++
-		}
-
-		if !evt.IsSilenced() {
-			gstatus.Status = calculateStatus(gstatus.Status, int(evt.Check.Status))
-		}
+		agg.Add(evt)
 	}
+	gstatus := agg.snapshot(anyNamespace)[entityName]
 
 	ctx.Errorf("Status for %s is %d", entityName, gstatus.Status)
 	ctx.Debugf("\tnb OK %d\tWarning %d\tCritical %d\tSilenced %d", gstatus.Ok, gstatus.Warning, gstatus.Critical, gstatus.Silenced)
@@ -125,45 +202,69 @@ func GetEntityStatus(entityName string, events []v2.Event) EntityStatus {
 	return gstatus
 }
 
-// GetEntitiesStatus : Get entities status based on a list of event
+// anyNamespace : Match the checks of every namespace, for lists of events of a single namespace
+func anyNamespace(key checkKey) bool {
+	return true
+}
+
+// GetEntitiesStatus : Get entities status based on a list of event.
+// The events are expected to be of a single namespace, see Aggregator.Snapshot otherwise
 func GetEntitiesStatus(events []v2.Event) map[string]EntityStatus {
 	ctx := log.WithFields(log.Fields{
 		"file":     "sensu/entities.go",
 		"function": "GetEntityStatus",
 	})
 
-	set := make(map[string]EntityStatus)
-
+	agg := NewAggregator()
 	for _, evt := range events {
-		var estatus EntityStatus
-		if _, ok := set[evt.Entity.Name]; ok {
-			// Update existing status
-			estatus = set[evt.Entity.Name]
+		agg.Add(evt)
+	}
+	set := agg.snapshot(anyNamespace)
+	for entity, estatus := range set {
+		ctx.Errorf("Setting %s status to %d", entity, estatus.Status)
+	}
+
+	return set
+}
+
+// GetStatusSummary : Count entities in each status
+func GetStatusSummary(statusMap map[string]EntityStatus) StatusSummary {
+	var summary StatusSummary
+
+	for _, status := range statusMap {
+		summary.Total++
+		if status.Status == sensu.CheckStateCritical {
+			summary.Critical++
+		} else if status.Status == sensu.CheckStateWarning {
+			summary.Warning++
+		} else if status.Status == sensu.CheckStateOK {
+			summary.Ok++
 		} else {
-			estatus = EntityStatus{}
+			summary.Unknown++
 		}
+	}
 
-		if evt.IsSilenced() {
-			estatus.Silenced++
-		}
-		if evt.Check.Status == sensu.CheckStateCritical {
-			estatus.Critical++
-		} else if evt.Check.Status == sensu.CheckStateWarning {
-			estatus.Warning++
-		} else if evt.Check.Status == sensu.CheckStateUnknown {
-			estatus.Unknown++
-		} else {
-			estatus.Ok++
+	return summary
+}
+
+// GetChangedEntities : Compare two entities status maps.
+// It will return the set of entities that appeared, disappeared or whose status changed
+func GetChangedEntities(previous map[string]EntityStatus, current map[string]EntityStatus) map[string]bool {
+	changed := make(map[string]bool)
+
+	for entity, status := range current {
+		if old, ok := previous[entity]; !ok || old.Status != status.Status {
+			changed[entity] = true
 		}
+	}
 
-		if !evt.IsSilenced() {
-			estatus.Status = calculateStatus(estatus.Status, int(evt.Check.Status))
+	for entity := range previous {
+		if _, ok := current[entity]; !ok {
+			changed[entity] = true
 		}
-		set[evt.Entity.Name] = estatus
-		ctx.Errorf("Setting %s status to %d", evt.Entity.Name, estatus.Status)
 	}
 
-	return set
+	return changed
 }
 
 func translateStatus(status int) string {
@@ -179,3 +280,39 @@ func translateStatus(status int) string {
 		return "UNKN"
 	}
 }
+
+// StatusName : Get the short name of a check status (OK, WARN, CRIT or UNKN)
+func StatusName(status int) string {
+	return translateStatus(status)
+}
+
+// StatusSeverity : Rank a check status from the least (OK) to the most (Critical) severe.
+// Unknown is ranked between OK and Warning, as in calculateStatus
+func StatusSeverity(status int) int {
+	switch status {
+	case sensu.CheckStateCritical:
+		return 3
+	case sensu.CheckStateWarning:
+		return 2
+	case sensu.CheckStateOK:
+		return 0
+	default:
+		return 1
+	}
+}
+
+// ParseStatus : Get a check status from its name (ok, warning, critical, unknown), short name or number
+func ParseStatus(name string) (int, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "ok", "0":
+		return sensu.CheckStateOK, nil
+	case "warning", "warn", "1":
+		return sensu.CheckStateWarning, nil
+	case "critical", "crit", "2":
+		return sensu.CheckStateCritical, nil
+	case "unknown", "unkn", "3":
+		return sensu.CheckStateUnknown, nil
+	default:
+		return 0, fmt.Errorf("unknown status %q, must be ok, warning, critical or unknown", name)
+	}
+}
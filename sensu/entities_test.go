@@ -147,6 +147,7 @@ func TestGetEntityStatus(t *testing.T) {
 	assert.Equal(ent4Status.Warning, 0)
 	assert.Equal(ent4Status.Critical, 0)
 	assert.Equal(ent4Status.Unknown, 1)
+	assert.Equal(ent4Status.Total, 1)
 }
 
 // GetEntitiesStatus : Get entities status based on a list of event
@@ -183,6 +184,7 @@ func TestGetEntitiesStatus(t *testing.T) {
 	assert.Equal(statuses["localhost"].Warning, 0)
 	assert.Equal(statuses["localhost"].Critical, 0)
 	assert.Equal(statuses["localhost"].Unknown, 0)
+	assert.Equal(statuses["localhost"].Total, 2)
 
 	assert.Equal(statuses["localhost2"].Status, sensu.CheckStateWarning)
 	assert.Equal(statuses["localhost2"].Silenced, 0)
@@ -221,3 +223,69 @@ func TestTranslateStatus(t *testing.T) {
 	assert.Equal(translateStatus(-1), "UNKN")
 	assert.Equal(translateStatus(MinInt), "UNKN")
 }
+
+func TestGetChangedEntities(t *testing.T) {
+	assert := assert.New(t)
+
+	previous := map[string]EntityStatus{
+		"localhost":  {Status: sensu.CheckStateOK},
+		"localhost2": {Status: sensu.CheckStateWarning},
+		"localhost3": {Status: sensu.CheckStateCritical},
+	}
+	current := map[string]EntityStatus{
+		"localhost":  {Status: sensu.CheckStateOK, Ok: 2},
+		"localhost2": {Status: sensu.CheckStateCritical},
+		"localhost4": {Status: sensu.CheckStateOK},
+	}
+
+	changed := GetChangedEntities(previous, current)
+
+	assert.Len(changed, 3)
+	assert.False(changed["localhost"])
+	assert.True(changed["localhost2"])
+	assert.True(changed["localhost3"])
+	assert.True(changed["localhost4"])
+
+	assert.Len(GetChangedEntities(current, current), 0)
+}
+
+func TestStatusSeverity(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Less(StatusSeverity(sensu.CheckStateOK), StatusSeverity(sensu.CheckStateUnknown))
+	assert.Less(StatusSeverity(sensu.CheckStateUnknown), StatusSeverity(sensu.CheckStateWarning))
+	assert.Less(StatusSeverity(sensu.CheckStateWarning), StatusSeverity(sensu.CheckStateCritical))
+	assert.Equal(StatusSeverity(sensu.CheckStateUnknown), StatusSeverity(255))
+}
+
+func TestParseStatus(t *testing.T) {
+	assert := assert.New(t)
+
+	for name, expected := range map[string]int{
+		"ok":       sensu.CheckStateOK,
+		"Warning":  sensu.CheckStateWarning,
+		"CRIT":     sensu.CheckStateCritical,
+		"critical": sensu.CheckStateCritical,
+		"3":        sensu.CheckStateUnknown,
+	} {
+		status, err := ParseStatus(name)
+		assert.NoError(err)
+		assert.Equal(expected, status, name)
+	}
+
+	_, err := ParseStatus("failing")
+	assert.Error(err)
+}
+
+func TestGetStatusSummary(t *testing.T) {
+	assert := assert.New(t)
+
+	summary := GetStatusSummary(map[string]EntityStatus{
+		"localhost":  {Status: sensu.CheckStateOK},
+		"localhost2": {Status: sensu.CheckStateCritical},
+		"localhost3": {Status: sensu.CheckStateCritical},
+		"localhost4": {Status: 255},
+	})
+
+	assert.Equal(StatusSummary{Total: 4, Critical: 2, Unknown: 1, Ok: 1}, summary)
+}
@@ -0,0 +1,45 @@
+package sensu
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	assert := assert.New(t)
+
+	path := filepath.Join(t.TempDir(), "status.txt")
+	assert.NoError(os.WriteFile(path, []byte("previous\n"), 0644))
+
+	// A failed write leaves the previous content in place
+	err := WriteFileAtomic(path, func(w io.Writer) error {
+		io.WriteString(w, "partial")
+		return errors.New("backend unavailable")
+	})
+	assert.Error(err)
+	content, _ := os.ReadFile(path)
+	assert.Equal("previous\n", string(content))
+
+	assert.NoError(WriteFileAtomic(path, func(w io.Writer) error {
+		_, err := io.WriteString(w, "current\n")
+		return err
+	}))
+	content, _ = os.ReadFile(path)
+	assert.Equal("current\n", string(content))
+
+	// Temporary files are cleaned up
+	files, _ := os.ReadDir(filepath.Dir(path))
+	assert.Len(files, 1)
+
+	// The mode of the replaced file is kept
+	assert.NoError(os.Chmod(path, 0600))
+	assert.NoError(WriteFileAtomic(path, func(w io.Writer) error { return nil }))
+	info, err := os.Stat(path)
+	assert.NoError(err)
+	assert.Equal(os.FileMode(0600), info.Mode().Perm())
+}
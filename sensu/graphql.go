@@ -0,0 +1,349 @@
+package sensu
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/apex/log"
+	v2 "github.com/sensu/core/v2"
+)
+
+// graphQLRequest : Body of a request to the GraphQL endpoint of the backend
+type graphQLRequest struct {
+	Query     string                 `json:"query"`
+	Variables map[string]interface{} `json:"variables"`
+}
+
+// graphQLResponse : Body of a response of the GraphQL endpoint of the backend
+type graphQLResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// postGraphQL : Run a query on the GraphQL endpoint of the backend and decode its data into out.
+// The request, the read of its response included, is given up after timeout
+func postGraphQL(ctx context.Context, apiURL string, header map[string]string, timeout time.Duration, query string, variables map[string]interface{}, out interface{}) error {
+	raw, err := json.Marshal(graphQLRequest{Query: query, Variables: variables})
+	if err != nil {
+		return err
+	}
+
+	reqCtx, cancel := withTimeout(ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(reqCtx, "POST", strings.TrimSuffix(apiURL, "/")+"/graphql", bytes.NewReader(raw))
+	if err != nil {
+		return err
+	}
+	for key, value := range header {
+		req.Header.Add(key, value)
+	}
+	req.Header.Add("Content-Type", "application/json")
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	resp, err := HTTPClient.Do(req)
+	if err != nil {
+		return timeoutCause(reqCtx, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("unexpected response from the backend GraphQL endpoint: %s", resp.Status)
+	}
+
+	body, err := newResponseBody(resp)
+	if err != nil {
+		return err
+	}
+	var result graphQLResponse
+	if err := json.NewDecoder(body).Decode(&result); err != nil {
+		return timeoutCause(reqCtx, err)
+	}
+	if err := body.Close(); err != nil {
+		return timeoutCause(reqCtx, err)
+	}
+	if len(result.Errors) > 0 {
+		messages := make([]string, 0, len(result.Errors))
+		for _, e := range result.Errors {
+			messages = append(messages, e.Message)
+		}
+		return fmt.Errorf("GraphQL query failed: %s", strings.Join(messages, "; "))
+	}
+
+	log.WithFields(log.Fields{
+		"file":     "sensu/graphql.go",
+		"function": "postGraphQL",
+	}).Debugf("GraphQL query performed, %d byte(s) transferred in %s", body.Transferred, body.Elapsed)
+	return json.Unmarshal(result.Data, out)
+}
+
+// leanEventsQuery : Events of a namespace, with only the fields the entities status needs
+const leanEventsQuery = `query LeanEvents($namespace: String!, $limit: Int!, $offset: Int!) {
+  namespace(name: $namespace) {
+    events(limit: $limit, offset: $offset) {
+      nodes {
+        timestamp
+        entity { name }
+        check { name status executed occurrences silenced history { status executed } }
+      }
+      pageInfo { hasNextPage nextOffset }
+    }
+  }
+}`
+
+// graphQLPageInfo : Offset pagination of the GraphQL lists
+type graphQLPageInfo struct {
+	HasNextPage bool `json:"hasNextPage"`
+	NextOffset  int  `json:"nextOffset"`
+}
+
+// graphQLCheck : Check of an event, as returned by the queries
+type graphQLCheck struct {
+	Name        string    `json:"name"`
+	Status      uint32    `json:"status"`
+	Executed    time.Time `json:"executed"`
+	Occurrences int64     `json:"occurrences"`
+	Silenced    []string  `json:"silenced"`
+	History     []struct {
+		Status   uint32    `json:"status"`
+		Executed time.Time `json:"executed"`
+	} `json:"history"`
+}
+
+// graphQLEvent : Event, as returned by the lean events query
+type graphQLEvent struct {
+	Timestamp time.Time `json:"timestamp"`
+	Entity    *struct {
+		Name string `json:"name"`
+	} `json:"entity"`
+	Check *graphQLCheck `json:"check"`
+}
+
+// unixTime : Unix timestamp of a GraphQL date, 0 when unset
+func unixTime(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+// toCheck : Build a check holding the fields returned by the queries
+func (c graphQLCheck) toCheck(namespace string) *v2.Check {
+	check := &v2.Check{
+		ObjectMeta:  v2.ObjectMeta{Name: c.Name, Namespace: namespace},
+		Status:      c.Status,
+		Executed:    unixTime(c.Executed),
+		Occurrences: c.Occurrences,
+		Silenced:    c.Silenced,
+	}
+	for _, history := range c.History {
+		check.History = append(check.History, v2.CheckHistory{Status: history.Status, Executed: unixTime(history.Executed)})
+	}
+	return check
+}
+
+// toEvent : Build an event holding the fields returned by the lean events query
+func (e graphQLEvent) toEvent(namespace string) v2.Event {
+	return v2.Event{
+		ObjectMeta: v2.ObjectMeta{Namespace: namespace},
+		Timestamp:  unixTime(e.Timestamp),
+		Entity:     &v2.Entity{ObjectMeta: v2.ObjectMeta{Name: e.Entity.Name, Namespace: namespace}},
+		Check:      e.Check.toCheck(namespace),
+	}
+}
+
+// graphQLPages : Run a query listing the objects of a namespace, one page of page.Size objects at a
+// time, and hand the nodes of every page to fn. The query takes the $namespace, $limit and $offset
+// variables and returns the list, with its nodes and pageInfo, under namespace
+func graphQLPages(ctx context.Context, apiURL string, namespace string, header map[string]string, page PageOptions, query string, list string, fn func(nodes json.RawMessage) (int, error)) error {
+	logCtx := log.WithFields(log.Fields{
+		"file":     "sensu/graphql.go",
+		"function": "graphQLPages",
+	})
+
+	size := page.Size
+	if size <= 0 {
+		size = DefaultPageSize
+	}
+
+	for offset, number := 0, 1; ; number++ {
+		var data struct {
+			Namespace map[string]struct {
+				Nodes    json.RawMessage `json:"nodes"`
+				PageInfo graphQLPageInfo `json:"pageInfo"`
+			} `json:"namespace"`
+		}
+
+		start := time.Now()
+		variables := map[string]interface{}{"namespace": namespace, "limit": size, "offset": offset}
+		if err := postGraphQL(ctx, apiURL, header, page.timeout(), query, variables, &data); err != nil {
+			return err
+		}
+		if data.Namespace == nil {
+			return fmt.Errorf("namespace %q not found", namespace)
+		}
+
+		result := data.Namespace[list]
+		count, err := fn(result.Nodes)
+		if err != nil {
+			return err
+		}
+		logCtx.Debugf("Page %d: %d %s in %s", number, count, list, time.Since(start))
+
+		if !result.PageInfo.HasNextPage || result.PageInfo.NextOffset <= offset {
+			return nil
+		}
+		offset = result.PageInfo.NextOffset
+	}
+}
+
+// StreamLeanEvents : Get the events of a namespace from the GraphQL endpoint of the backend, with only
+// the entity name, check name, status, silences, occurrences and execution times, and hand them to fn
+// one at a time. The REST API has no reduced representation of the events
+func StreamLeanEvents(ctx context.Context, apiURL string, namespace string, header map[string]string, page PageOptions, fn func(evt v2.Event) error) error {
+	return graphQLPages(ctx, apiURL, namespace, header, page, leanEventsQuery, "events", func(raw json.RawMessage) (int, error) {
+		var nodes []graphQLEvent
+		if err := json.Unmarshal(raw, &nodes); err != nil {
+			return 0, err
+		}
+		for _, node := range nodes {
+			if node.Entity == nil || node.Check == nil {
+				continue
+			}
+			if err := fn(node.toEvent(namespace)); err != nil {
+				return 0, err
+			}
+		}
+		return len(nodes), nil
+	})
+}
+
+// EventExtractLean : Get the events of a namespace with only the fields the entities status needs,
+// see StreamLeanEvents
+func EventExtractLean(ctx context.Context, apiURL string, namespace string, header map[string]string, page PageOptions) ([]v2.Event, error) {
+	eventResults := []v2.Event{}
+	err := StreamLeanEvents(ctx, apiURL, namespace, header, page, func(evt v2.Event) error {
+		eventResults = append(eventResults, evt)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return eventResults, nil
+}
+
+// entitiesHealthQuery : Entities of a namespace with their events and the silences of those events
+const entitiesHealthQuery = `query EntitiesHealth($namespace: String!, $limit: Int!, $offset: Int!) {
+  namespace(name: $namespace) {
+    entities(limit: $limit, offset: $offset) {
+      nodes {
+        name
+        entityClass
+        subscriptions
+        lastSeen
+        metadata { labels { key val } }
+        events {
+          timestamp
+          silences { name }
+          check { name status executed occurrences history { status executed } }
+        }
+      }
+      pageInfo { hasNextPage nextOffset }
+    }
+  }
+}`
+
+// graphQLEntity : Entity, as returned by the entities health query
+type graphQLEntity struct {
+	Name          string    `json:"name"`
+	EntityClass   string    `json:"entityClass"`
+	Subscriptions []string  `json:"subscriptions"`
+	LastSeen      time.Time `json:"lastSeen"`
+	Metadata      struct {
+		Labels []struct {
+			Key string `json:"key"`
+			Val string `json:"val"`
+		} `json:"labels"`
+	} `json:"metadata"`
+	Events []struct {
+		Timestamp time.Time `json:"timestamp"`
+		Silences  []struct {
+			Name string `json:"name"`
+		} `json:"silences"`
+		Check *graphQLCheck `json:"check"`
+	} `json:"events"`
+}
+
+// toEvents : Build the events of an entity, every event holding the entity details
+func (e graphQLEntity) toEvents(namespace string) []v2.Event {
+	entity := &v2.Entity{
+		ObjectMeta:    v2.ObjectMeta{Name: e.Name, Namespace: namespace},
+		EntityClass:   e.EntityClass,
+		Subscriptions: e.Subscriptions,
+		LastSeen:      unixTime(e.LastSeen),
+	}
+	if len(e.Metadata.Labels) > 0 {
+		entity.Labels = make(map[string]string, len(e.Metadata.Labels))
+		for _, label := range e.Metadata.Labels {
+			entity.Labels[label.Key] = label.Val
+		}
+	}
+
+	var events []v2.Event
+	for _, node := range e.Events {
+		if node.Check == nil {
+			continue
+		}
+		check := *node.Check
+		check.Silenced = nil
+		for _, silence := range node.Silences {
+			check.Silenced = append(check.Silenced, silence.Name)
+		}
+		evt := v2.Event{
+			ObjectMeta: v2.ObjectMeta{Namespace: namespace},
+			Timestamp:  unixTime(node.Timestamp),
+			Entity:     entity,
+			Check:      check.toCheck(namespace),
+		}
+		events = append(events, evt)
+	}
+	return events
+}
+
+// StreamGraphQLEvents : Get the entities of a namespace with their events and silences from the GraphQL
+// endpoint of the backend, one page of entities per request, and hand their events to fn one at a time.
+// Entities without events are skipped, as they are by the REST API
+func StreamGraphQLEvents(ctx context.Context, apiURL string, namespace string, header map[string]string, page PageOptions, fn func(evt v2.Event) error) error {
+	return graphQLPages(ctx, apiURL, namespace, header, page, entitiesHealthQuery, "entities", func(raw json.RawMessage) (int, error) {
+		var nodes []graphQLEntity
+		if err := json.Unmarshal(raw, &nodes); err != nil {
+			return 0, err
+		}
+		for _, node := range nodes {
+			for _, evt := range node.toEvents(namespace) {
+				if err := fn(evt); err != nil {
+					return 0, err
+				}
+			}
+		}
+		return len(nodes), nil
+	})
+}
+
+// EventExtractGraphQL : Get the events of a namespace from the GraphQL endpoint, see StreamGraphQLEvents
+func EventExtractGraphQL(ctx context.Context, apiURL string, namespace string, header map[string]string, page PageOptions) ([]v2.Event, error) {
+	eventResults := []v2.Event{}
+	err := StreamGraphQLEvents(ctx, apiURL, namespace, header, page, func(evt v2.Event) error {
+		eventResults = append(eventResults, evt)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return eventResults, nil
+}
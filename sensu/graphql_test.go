@@ -0,0 +1,142 @@
+package sensu
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sensu/sensu-plugin-sdk/sensu"
+	"github.com/stretchr/testify/assert"
+)
+
+// newGraphQLServer : Serve the lean events query for the default namespace, 3 events per page
+func newGraphQLServer(t *testing.T) *httptest.Server {
+	nodes := []string{
+		`{"timestamp": "2024-05-01T10:00:00Z", "entity": {"name": "web1"}, "check": {"name": "http", "status": 2, "executed": "2024-05-01T10:00:00Z", "occurrences": 4, "silenced": [], "history": [{"status": 0, "executed": "2024-05-01T09:59:00Z"}, {"status": 2, "executed": "2024-05-01T10:00:00Z"}]}}`,
+		`{"timestamp": "2024-05-01T10:00:00Z", "entity": {"name": "web1"}, "check": {"name": "disk", "status": 0, "executed": "2024-05-01T10:00:00Z", "occurrences": 1, "silenced": [], "history": []}}`,
+		`{"timestamp": "2024-05-01T10:00:00Z", "entity": {"name": "db1"}, "check": {"name": "disk", "status": 1, "executed": "2024-05-01T10:00:00Z", "occurrences": 1, "silenced": ["*:disk"], "history": []}}`,
+		`{"timestamp": "2024-05-01T10:00:00Z", "entity": {"name": "db2"}, "check": {"name": "disk", "status": 0, "executed": "2024-05-01T10:00:00Z", "occurrences": 1, "silenced": [], "history": []}}`,
+		`{"timestamp": "2024-05-01T10:00:00Z", "entity": {"name": "db3"}, "check": null}`,
+	}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/graphql" {
+			http.NotFound(w, r)
+			return
+		}
+		var req graphQLRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+		if req.Variables["namespace"] != "default" {
+			fmt.Fprint(w, `{"data": {"namespace": null}}`)
+			return
+		}
+
+		offset := int(req.Variables["offset"].(float64))
+		end := min(offset+3, len(nodes))
+		page := "["
+		for i, node := range nodes[offset:end] {
+			if i > 0 {
+				page += ","
+			}
+			page += node
+		}
+		page += "]"
+		fmt.Fprintf(w, `{"data": {"namespace": {"events": {"nodes": %s, "pageInfo": {"hasNextPage": %t, "nextOffset": %d}}}}}`,
+			page, end < len(nodes), end)
+	}))
+}
+
+func TestEventExtractLean(t *testing.T) {
+	assert := assert.New(t)
+
+	server := newGraphQLServer(t)
+	defer server.Close()
+
+	events, err := EventExtractLean(context.Background(), server.URL, "default", nil, PageOptions{Size: 3})
+	assert.NoError(err)
+	// The event without check is skipped
+	assert.Len(events, 4)
+	assert.Equal("web1", events[0].Entity.Name)
+	assert.Equal("default", events[0].Entity.Namespace)
+	assert.Equal("http", events[0].Check.Name)
+	assert.Equal(int64(1714557600), events[0].Check.Executed)
+	assert.Len(events[0].Check.History, 2)
+	assert.True(events[2].IsSilenced())
+
+	statuses := GetEntitiesStatus(events)
+	assert.Equal(sensu.CheckStateCritical, statuses["web1"].Status)
+	assert.Equal(4, statuses["web1"].FailingStreak)
+	// Silenced warning
+	assert.Equal(sensu.CheckStateOK, statuses["db1"].Status)
+	assert.Equal(1, statuses["db1"].Silenced)
+}
+
+func TestEventExtractLeanErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	server := newGraphQLServer(t)
+	defer server.Close()
+
+	_, err := EventExtractLean(context.Background(), server.URL, "missing", nil, PageOptions{})
+	assert.ErrorContains(err, `namespace "missing" not found`)
+
+	_, err = EventExtractLean(context.Background(), server.URL+"/api", "default", nil, PageOptions{})
+	assert.ErrorContains(err, "404")
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"data": null, "errors": [{"message": "unauthorized"}]}`)
+	}))
+	defer failing.Close()
+	_, err = EventExtractLean(context.Background(), failing.URL, "default", nil, PageOptions{})
+	assert.ErrorContains(err, "unauthorized")
+}
+
+func TestEventExtractGraphQL(t *testing.T) {
+	assert := assert.New(t)
+
+	pages := []string{
+		`[{"name": "web1", "entityClass": "agent", "subscriptions": ["linux", "entity:web1"], "lastSeen": "2024-05-01T10:00:00Z",
+			"metadata": {"labels": [{"key": "team", "val": "web"}]},
+			"events": [
+				{"timestamp": "2024-05-01T10:00:00Z", "silences": [], "check": {"name": "http", "status": 2, "executed": "2024-05-01T10:00:00Z", "occurrences": 1, "history": []}},
+				{"timestamp": "2024-05-01T10:00:00Z", "silences": [{"name": "*:disk"}], "check": {"name": "disk", "status": 1, "executed": "2024-05-01T10:00:00Z", "occurrences": 1, "history": []}}
+			]},
+		  {"name": "proxy1", "entityClass": "proxy", "subscriptions": [], "lastSeen": null, "metadata": {"labels": null}, "events": []}]`,
+		`[{"name": "db1", "entityClass": "agent", "subscriptions": [], "lastSeen": "2024-05-01T10:00:00Z", "metadata": {"labels": []},
+			"events": [{"timestamp": "2024-05-01T10:00:00Z", "silences": [], "check": {"name": "disk", "status": 0, "executed": "2024-05-01T10:00:00Z", "occurrences": 1, "history": []}}]}]`,
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req graphQLRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+		offset := int(req.Variables["offset"].(float64))
+		fmt.Fprintf(w, `{"data": {"namespace": {"entities": {"nodes": %s, "pageInfo": {"hasNextPage": %t, "nextOffset": %d}}}}}`,
+			pages[offset/2], offset == 0, offset+2)
+	}))
+	defer server.Close()
+
+	events, err := EventExtractGraphQL(context.Background(), server.URL, "default", nil, PageOptions{Size: 2})
+	assert.NoError(err)
+	// The proxy entity has no event
+	assert.Len(events, 3)
+	assert.Equal("agent", events[0].Entity.EntityClass)
+	assert.Equal("web", events[0].Entity.Labels["team"])
+	assert.Equal([]string{"*:disk"}, events[1].Check.Silenced)
+
+	statuses := GetEntitiesStatus(events)
+	assert.Len(statuses, 2)
+	assert.Equal(sensu.CheckStateCritical, statuses["web1"].Status)
+	assert.Equal(1, statuses["web1"].Silenced)
+	assert.Equal(sensu.CheckStateOK, statuses["db1"].Status)
+
+	info := GetEntitiesInfo(events)
+	assert.Equal([]string{"linux"}, info["web1"].Subscriptions)
+	assert.Equal(int64(1714557600), info["web1"].LastSeen)
+}
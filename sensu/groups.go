@@ -0,0 +1,100 @@
+package sensu
+
+import (
+	"sort"
+
+	"github.com/apex/log"
+	v2 "github.com/sensu/core/v2"
+	"github.com/sensu/sensu-plugin-sdk/sensu"
+)
+
+// UngroupedName : Name of the group of entities without the grouping label
+const UngroupedName = "ungrouped"
+
+// GroupStatus : Structure used to sumarize the current State of a group of entities
+type GroupStatus struct {
+	Status   int      `json:"status" yaml:"status"`
+	Critical int      `json:"critical" yaml:"critical"`
+	Warning  int      `json:"warning" yaml:"warning"`
+	Unknown  int      `json:"unknown" yaml:"unknown"`
+	Ok       int      `json:"ok" yaml:"ok"`
+	Total    int      `json:"total" yaml:"total"`
+	Entities []string `json:"entities" yaml:"entities"`
+}
+
+// GetEntityGroup : Get the group an entity belongs to.
+// Entities are grouped by the value of the given label, or by entity class if no label is given
+func GetEntityGroup(entity *v2.Entity, label string) string {
+	if entity == nil {
+		return UngroupedName
+	}
+
+	group := entity.EntityClass
+	if len(label) > 0 {
+		group = entity.ObjectMeta.Labels[label]
+	}
+	if len(group) == 0 {
+		return UngroupedName
+	}
+
+	return group
+}
+
+// GetEntitiesGroups : Get the group of every entity based on a list of event
+func GetEntitiesGroups(events []v2.Event, label string) map[string]string {
+	membership := make(map[string]string)
+	for _, evt := range events {
+		if evt.Entity == nil {
+			continue
+		}
+		if _, ok := membership[evt.Entity.Name]; !ok {
+			membership[evt.Entity.Name] = GetEntityGroup(evt.Entity, label)
+		}
+	}
+	return membership
+}
+
+// GetGroupsStatus : Get groups status based on a list of event and on the matching entities status.
+// Counters of a group are the number of entities in each status
+func GetGroupsStatus(events []v2.Event, statusMap map[string]EntityStatus, label string) map[string]GroupStatus {
+	return GroupEntitiesStatus(GetEntitiesGroups(events, label), statusMap)
+}
+
+// GroupEntitiesStatus : Get groups status based on the group of every entity, as returned by
+// GetEntitiesGroups, and on the entities status
+func GroupEntitiesStatus(membership map[string]string, statusMap map[string]EntityStatus) map[string]GroupStatus {
+	ctx := log.WithFields(log.Fields{
+		"file":     "sensu/groups.go",
+		"function": "GroupEntitiesStatus",
+	})
+
+	groups := make(map[string]GroupStatus)
+	for entity, status := range statusMap {
+		name, ok := membership[entity]
+		if !ok {
+			name = UngroupedName
+		}
+
+		group := groups[name]
+		group.Total++
+		group.Entities = append(group.Entities, entity)
+		if status.Status == sensu.CheckStateCritical {
+			group.Critical++
+		} else if status.Status == sensu.CheckStateWarning {
+			group.Warning++
+		} else if status.Status == sensu.CheckStateOK {
+			group.Ok++
+		} else {
+			group.Unknown++
+		}
+		group.Status = calculateStatus(group.Status, status.Status)
+		groups[name] = group
+	}
+
+	for name, group := range groups {
+		sort.Strings(group.Entities)
+		ctx.Debugf("Status for group %s is %d (%d entities)", name, group.Status, group.Total)
+	}
+
+	return groups
+}
@@ -0,0 +1,57 @@
+package sensu
+
+import (
+	"testing"
+
+	corev2 "github.com/sensu/core/v2"
+	"github.com/sensu/sensu-plugin-sdk/sensu"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetEntityGroup(t *testing.T) {
+	assert := assert.New(t)
+
+	entity := corev2.FixtureEntity("localhost")
+	entity.EntityClass = "agent"
+	entity.ObjectMeta.Labels = map[string]string{"team": "web"}
+
+	assert.Equal("agent", GetEntityGroup(entity, ""))
+	assert.Equal("web", GetEntityGroup(entity, "team"))
+	assert.Equal(UngroupedName, GetEntityGroup(entity, "region"))
+	assert.Equal(UngroupedName, GetEntityGroup(nil, "team"))
+}
+
+func TestGetGroupsStatus(t *testing.T) {
+	assert := assert.New(t)
+
+	evt1 := *corev2.FixtureEvent("web1", "dummy-check1")
+	evt2 := *corev2.FixtureEvent("web2", "dummy-check1")
+	evt3 := *corev2.FixtureEvent("db1", "dummy-check1")
+	evt4 := *corev2.FixtureEvent("localhost", "dummy-check1")
+
+	evt1.Entity.ObjectMeta.Labels = map[string]string{"team": "web"}
+	evt2.Entity.ObjectMeta.Labels = map[string]string{"team": "web"}
+	evt3.Entity.ObjectMeta.Labels = map[string]string{"team": "db"}
+
+	evt1.Check.Status = sensu.CheckStateOK
+	evt2.Check.Status = sensu.CheckStateWarning
+	evt3.Check.Status = sensu.CheckStateCritical
+	evt4.Check.Status = sensu.CheckStateOK
+
+	eventList := []corev2.Event{evt1, evt2, evt3, evt4}
+	groups := GetGroupsStatus(eventList, GetEntitiesStatus(eventList), "team")
+
+	assert.Len(groups, 3)
+
+	assert.Equal(sensu.CheckStateWarning, groups["web"].Status)
+	assert.Equal(2, groups["web"].Total)
+	assert.Equal(1, groups["web"].Ok)
+	assert.Equal(1, groups["web"].Warning)
+	assert.Equal([]string{"web1", "web2"}, groups["web"].Entities)
+
+	assert.Equal(sensu.CheckStateCritical, groups["db"].Status)
+	assert.Equal(1, groups["db"].Critical)
+
+	assert.Equal(sensu.CheckStateOK, groups[UngroupedName].Status)
+	assert.Equal([]string{"localhost"}, groups[UngroupedName].Entities)
+}
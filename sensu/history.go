@@ -0,0 +1,319 @@
+package sensu
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/apex/log"
+	v2 "github.com/sensu/core/v2"
+	"gopkg.in/yaml.v2"
+)
+
+// DefaultHistoryRetention : How long collection runs are kept in the history file
+const DefaultHistoryRetention = 7 * 24 * time.Hour
+
+// HistoryEntity : Status of an entity and of its checks at a collection run
+type HistoryEntity struct {
+	Status int            `json:"status"`
+	Checks map[string]int `json:"checks,omitempty"`
+}
+
+// HistoryRecord : Result of a collection run, as stored in the history file
+type HistoryRecord struct {
+	Time      time.Time                `json:"time"`
+	Namespace string                   `json:"namespace"`
+	Entities  map[string]HistoryEntity `json:"entities"`
+}
+
+// NewHistoryRecord : Build the history record of a collection run
+func NewHistoryRecord(events []v2.Event, namespace string, ts time.Time) HistoryRecord {
+	agg := NewAggregator()
+	for _, evt := range events {
+		agg.Add(evt)
+	}
+	return agg.historyRecord(anyNamespace, namespace, ts)
+}
+
+// HistoryStore : Collection runs stored in a single file, one JSON record per line.
+// Records older than the retention are dropped when a record is appended
+type HistoryStore struct {
+	Path      string
+	Retention time.Duration
+}
+
+// Load : Read the records of a namespace, oldest first. A missing file is an empty history
+func (s HistoryStore) Load(namespace string) ([]HistoryRecord, error) {
+	records, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	return selectNamespace(records, namespace), nil
+}
+
+func selectNamespace(records []HistoryRecord, namespace string) []HistoryRecord {
+	var selected []HistoryRecord
+	for _, record := range records {
+		if record.Namespace == namespace {
+			selected = append(selected, record)
+		}
+	}
+	return selected
+}
+
+func (s HistoryStore) load() ([]HistoryRecord, error) {
+	f, err := os.Open(s.Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []HistoryRecord
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var record HistoryRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("invalid history record %s:%d: %w", s.Path, line, err)
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(records, func(i, j int) bool { return records[i].Time.Before(records[j].Time) })
+	return records, nil
+}
+
+// Append : Add a record to the history file, dropping the records older than the retention
+func (s HistoryStore) Append(record HistoryRecord) error {
+	return s.Update(record, nil)
+}
+
+// Update : Add a record to the history file, after handing apply, when not nil, the records of its
+// namespace already stored, oldest first. The file is locked meanwhile, so that concurrent runs
+// neither lose records nor see the file being rewritten
+func (s HistoryStore) Update(record HistoryRecord, apply func(records []HistoryRecord)) error {
+	lock, err := os.OpenFile(s.Path+".lock", os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+	if err := lockFile(lock); err != nil {
+		return fmt.Errorf("locking history file %s: %w", s.Path, err)
+	}
+
+	records, err := s.load()
+	if err != nil {
+		return err
+	}
+	if apply != nil {
+		apply(selectNamespace(records, record.Namespace))
+	}
+	return s.append(records, record)
+}
+
+// append : Add a record to the history file holding the given records.
+// Records older than the retention are dropped in batches, the file is only rewritten once the
+// oldest record is older than the retention by a tenth of it, it is appended to otherwise
+func (s HistoryStore) append(records []HistoryRecord, record HistoryRecord) error {
+	ctx := log.WithFields(log.Fields{
+		"file":     "sensu/history.go",
+		"function": "append",
+	})
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	retention := s.Retention
+	if retention <= 0 {
+		retention = DefaultHistoryRetention
+	}
+	oldest := record.Time.Add(-retention)
+	if len(records) == 0 || !records[0].Time.Before(oldest.Add(-retention/10)) {
+		f, err := os.OpenFile(s.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(append(line, '\n')); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	}
+
+	ctx.Debugf("Dropping history records older than %s", oldest)
+	return WriteFileAtomic(s.Path, func(w io.Writer) error {
+		enc := json.NewEncoder(w)
+		for _, kept := range records {
+			if kept.Time.Before(oldest) {
+				continue
+			}
+			if err := enc.Encode(kept); err != nil {
+				return err
+			}
+		}
+		_, err := w.Write(append(line, '\n'))
+		return err
+	})
+}
+
+// ApplyHistory : Set the time the entities entered their current status and their number of
+// status changes, based on the history records, oldest first
+func ApplyHistory(statusMap map[string]EntityStatus, records []HistoryRecord, now time.Time) {
+	for entity, status := range statusMap {
+		status.Since = now.Unix()
+		status.Flaps = 0
+
+		var previous *int
+		for _, record := range records {
+			recorded, ok := record.Entities[entity]
+			if !ok {
+				continue
+			}
+			if previous != nil && *previous != recorded.Status {
+				status.Flaps++
+			}
+			previous = &recorded.Status
+		}
+		if previous != nil && *previous != status.Status {
+			status.Flaps++
+		}
+
+		// Walk back to the first record of the current status
+		for i := len(records) - 1; i >= 0; i-- {
+			recorded, ok := records[i].Entities[entity]
+			if !ok || recorded.Status != status.Status {
+				break
+			}
+			status.Since = records[i].Time.Unix()
+		}
+
+		statusMap[entity] = status
+	}
+}
+
+// TimelinePeriod : Period during which an entity and its checks kept the same status
+type TimelinePeriod struct {
+	From   time.Time      `json:"from" yaml:"from"`
+	To     time.Time      `json:"to" yaml:"to"`
+	Status int            `json:"status" yaml:"status"`
+	Checks map[string]int `json:"checks" yaml:"checks"`
+	Runs   int            `json:"runs" yaml:"runs"`
+}
+
+// GetEntityTimeline : Group the consecutive records where an entity and its checks kept the same status.
+// Runs where the entity had no event end the current period
+func GetEntityTimeline(records []HistoryRecord, entity string) []TimelinePeriod {
+	periods := []TimelinePeriod{}
+
+	var current *TimelinePeriod
+	for _, record := range records {
+		recorded, ok := record.Entities[entity]
+		if !ok {
+			current = nil
+			continue
+		}
+		if current != nil && current.Status == recorded.Status && sameChecks(current.Checks, recorded.Checks) {
+			current.To = record.Time
+			current.Runs++
+			continue
+		}
+		periods = append(periods, TimelinePeriod{
+			From:   record.Time,
+			To:     record.Time,
+			Status: recorded.Status,
+			Checks: recorded.Checks,
+			Runs:   1,
+		})
+		current = &periods[len(periods)-1]
+	}
+
+	return periods
+}
+
+func sameChecks(a map[string]int, b map[string]int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for check, status := range a {
+		if other, ok := b[check]; !ok || other != status {
+			return false
+		}
+	}
+	return true
+}
+
+// failingChecks : Checks not OK, worst first, as CHECK=STATUS
+func failingChecks(checks map[string]int) string {
+	var names []string
+	for check, status := range checks {
+		if status != 0 {
+			names = append(names, check)
+		}
+	}
+	sort.SliceStable(names, func(i, j int) bool {
+		if checks[names[i]] != checks[names[j]] {
+			return StatusSeverity(checks[names[i]]) > StatusSeverity(checks[names[j]])
+		}
+		return names[i] < names[j]
+	})
+
+	failing := make([]string, 0, len(names))
+	for _, check := range names {
+		failing = append(failing, check+"="+translateStatus(checks[check]))
+	}
+	return strings.Join(failing, ",")
+}
+
+// WriteTimelineTable : Write the timeline of an entity as a table
+func WriteTimelineTable(w io.Writer, periods []TimelinePeriod) error {
+	tw := tabwriter.NewWriter(w, 1, 1, 3, ' ', tabwriter.AlignRight|tabwriter.Debug)
+	fmt.Fprintln(tw, "From\tTo\tDuration\tStatus\tRuns\tFailing checks")
+	fmt.Fprintln(tw, "----\t--\t--------\t------\t----\t--------------")
+	for _, period := range periods {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%s\n",
+			period.From.Local().Format("2006-01-02 15:04:05"),
+			period.To.Local().Format("2006-01-02 15:04:05"),
+			period.To.Sub(period.From).Round(time.Second),
+			translateStatus(period.Status),
+			period.Runs,
+			failingChecks(period.Checks),
+		)
+	}
+	return tw.Flush()
+}
+
+// WriteTimelineJSON : Write the timeline of an entity in JSON format
+func WriteTimelineJSON(w io.Writer, periods []TimelinePeriod) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(periods)
+}
+
+// WriteTimelineYAML : Write the timeline of an entity in YAML format
+func WriteTimelineYAML(w io.Writer, periods []TimelinePeriod) error {
+	raw, err := yaml.Marshal(periods)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(raw)
+	return err
+}
@@ -0,0 +1,162 @@
+package sensu
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	corev2 "github.com/sensu/core/v2"
+	"github.com/sensu/sensu-plugin-sdk/sensu"
+	"github.com/stretchr/testify/assert"
+)
+
+func historyRecord(ts int64, status int, checks map[string]int) HistoryRecord {
+	return HistoryRecord{
+		Time:      time.Unix(ts, 0).UTC(),
+		Namespace: "default",
+		Entities:  map[string]HistoryEntity{"web": {Status: status, Checks: checks}},
+	}
+}
+
+func TestNewHistoryRecord(t *testing.T) {
+	assert := assert.New(t)
+
+	disk := corev2.FixtureEvent("web", "disk")
+	disk.Check.Status = sensu.CheckStateWarning
+	cpu := corev2.FixtureEvent("web", "cpu")
+
+	record := NewHistoryRecord([]corev2.Event{*disk, *cpu}, "default", time.Unix(1700000000, 0))
+	assert.Equal(map[string]HistoryEntity{
+		"web": {Status: sensu.CheckStateWarning, Checks: map[string]int{"disk": 1, "cpu": 0}},
+	}, record.Entities)
+}
+
+func TestHistoryStore(t *testing.T) {
+	assert := assert.New(t)
+
+	store := HistoryStore{Path: filepath.Join(t.TempDir(), "history.ndjson"), Retention: time.Hour}
+
+	records, err := store.Load("default")
+	assert.NoError(err)
+	assert.Empty(records)
+
+	assert.NoError(store.Append(historyRecord(1700000000, 0, nil)))
+	assert.NoError(store.Append(historyRecord(1700001800, 2, nil)))
+	other := historyRecord(1700001800, 0, nil)
+	other.Namespace = "production"
+	assert.NoError(store.Append(other))
+
+	records, err = store.Load("default")
+	assert.NoError(err)
+	assert.Len(records, 2)
+
+	// Records older than the retention are dropped
+	assert.NoError(store.Append(historyRecord(1700004000, 2, nil)))
+	records, err = store.Load("default")
+	assert.NoError(err)
+	assert.Len(records, 2)
+	assert.Equal(int64(1700001800), records[0].Time.Unix())
+
+	raw, _ := os.ReadFile(store.Path)
+	assert.Equal(3, strings.Count(string(raw), "\n"))
+
+	assert.NoError(os.WriteFile(store.Path, []byte("{not json\n"), 0644))
+	_, err = store.Load("default")
+	assert.Error(err)
+}
+
+func TestHistoryStoreUpdate(t *testing.T) {
+	assert := assert.New(t)
+
+	store := HistoryStore{Path: filepath.Join(t.TempDir(), "history.ndjson"), Retention: time.Hour}
+	assert.NoError(store.Append(historyRecord(1700000000, 0, nil)))
+	other := historyRecord(1700000000, 0, nil)
+	other.Namespace = "production"
+	assert.NoError(store.Append(other))
+
+	// The records of the namespace stored before are handed over
+	var previous []HistoryRecord
+	assert.NoError(store.Update(historyRecord(1700003700, 2, nil), func(records []HistoryRecord) {
+		previous = records
+	}))
+	assert.Len(previous, 1)
+	assert.Equal(int64(1700000000), previous[0].Time.Unix())
+
+	// Records barely older than the retention are kept until the file is rewritten
+	raw, _ := os.ReadFile(store.Path)
+	assert.Equal(3, strings.Count(string(raw), "\n"))
+
+	// Concurrent runs do not lose records
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(store.Append(historyRecord(1700003800+int64(i), 0, nil)))
+		}()
+	}
+	wg.Wait()
+	records, err := store.Load("default")
+	assert.NoError(err)
+	assert.Len(records, 22)
+}
+
+func TestApplyHistory(t *testing.T) {
+	assert := assert.New(t)
+
+	records := []HistoryRecord{
+		historyRecord(1700000000, 0, nil),
+		historyRecord(1700000060, 2, nil),
+		historyRecord(1700000120, 0, nil),
+		historyRecord(1700000180, 2, nil),
+		historyRecord(1700000240, 2, nil),
+	}
+
+	statusMap := map[string]EntityStatus{
+		"web": {Status: sensu.CheckStateCritical},
+		"db":  {Status: sensu.CheckStateOK},
+	}
+	ApplyHistory(statusMap, records, time.Unix(1700000300, 0))
+	assert.Equal(int64(1700000180), statusMap["web"].Since)
+	assert.Equal(3, statusMap["web"].Flaps)
+	// No history yet
+	assert.Equal(int64(1700000300), statusMap["db"].Since)
+	assert.Equal(0, statusMap["db"].Flaps)
+
+	// Recovery at the current run
+	statusMap = map[string]EntityStatus{"web": {Status: sensu.CheckStateOK}}
+	ApplyHistory(statusMap, records, time.Unix(1700000300, 0))
+	assert.Equal(int64(1700000300), statusMap["web"].Since)
+	assert.Equal(4, statusMap["web"].Flaps)
+}
+
+func TestGetEntityTimeline(t *testing.T) {
+	assert := assert.New(t)
+
+	records := []HistoryRecord{
+		historyRecord(1700000000, 0, map[string]int{"disk": 0, "cpu": 0}),
+		historyRecord(1700000060, 0, map[string]int{"disk": 0, "cpu": 0}),
+		historyRecord(1700000120, 2, map[string]int{"disk": 2, "cpu": 1}),
+		historyRecord(1700000180, 2, map[string]int{"disk": 2, "cpu": 0}),
+		{Time: time.Unix(1700000240, 0).UTC(), Namespace: "default", Entities: map[string]HistoryEntity{}},
+		historyRecord(1700000300, 2, map[string]int{"disk": 2, "cpu": 0}),
+	}
+
+	periods := GetEntityTimeline(records, "web")
+	assert.Len(periods, 4)
+	assert.Equal(2, periods[0].Runs)
+	assert.Equal(time.Unix(1700000060, 0).UTC(), periods[0].To)
+	assert.Empty(GetEntityTimeline(records, "db"))
+
+	var buf bytes.Buffer
+	assert.NoError(WriteTimelineTable(&buf, periods))
+	lines := strings.Split(buf.String(), "\n")
+	assert.Len(lines, 7)
+	assert.True(strings.HasSuffix(lines[0], "|Failing checks"))
+	assert.True(strings.HasSuffix(lines[2], "1m0s|       OK|      2|"))
+	assert.True(strings.HasSuffix(lines[3], "0s|     CRIT|      1|disk=CRIT,cpu=WARN"))
+}
@@ -0,0 +1,237 @@
+package sensu
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/apex/log"
+	v2 "github.com/sensu/core/v2"
+	"gopkg.in/yaml.v2"
+)
+
+// wrappedResource : Resource in a sensuctl envelope, as found in sensuctl dump outputs
+type wrappedResource struct {
+	Type       string          `json:"type"`
+	APIVersion string          `json:"api_version"`
+	Metadata   v2.ObjectMeta   `json:"metadata"`
+	Spec       json.RawMessage `json:"spec"`
+}
+
+// Resources : Events, entities and silences read offline
+type Resources struct {
+	Events   []v2.Event
+	Entities []v2.Entity
+	Silences []v2.Silenced
+}
+
+// ReadEvents : Read events offline. The input is either JSON (an array of events as returned by
+// the API, newline-delimited events or sensuctl wrapped-json resources) or sensuctl yaml resources,
+// one document per resource. Resources other than events are skipped
+func ReadEvents(r io.Reader) ([]v2.Event, error) {
+	resources, err := ReadResources(r)
+	if err != nil {
+		return nil, err
+	}
+	return resources.Events, nil
+}
+
+// ReadResources : Read events, entities and silences offline, see ReadEvents. Plain JSON objects are
+// events, sensuctl resources of other types are skipped
+func ReadResources(r io.Reader) (Resources, error) {
+	ctx := log.WithFields(log.Fields{
+		"file":     "sensu/input.go",
+		"function": "ReadResources",
+	})
+
+	res := Resources{Events: []v2.Event{}}
+	br := bufio.NewReader(r)
+	first, err := firstByte(br)
+	if err != nil {
+		return res, err
+	}
+
+	if first == '[' || first == '{' {
+		dec := json.NewDecoder(br)
+		for {
+			var raw json.RawMessage
+			if err := dec.Decode(&raw); errors.Is(err, io.EOF) {
+				break
+			} else if err != nil {
+				return res, fmt.Errorf("invalid JSON input: %w", err)
+			}
+			if err := res.add(raw); err != nil {
+				return res, err
+			}
+		}
+	} else if first != 0 {
+		dec := yaml.NewDecoder(br)
+		for {
+			var document interface{}
+			if err := dec.Decode(&document); errors.Is(err, io.EOF) {
+				break
+			} else if err != nil {
+				return res, fmt.Errorf("invalid YAML input: %w", err)
+			}
+			if document == nil {
+				// Empty document
+				continue
+			}
+			raw, err := json.Marshal(jsonValue(document))
+			if err != nil {
+				return res, fmt.Errorf("invalid YAML input: %w", err)
+			}
+			if err := res.add(raw); err != nil {
+				return res, err
+			}
+		}
+	}
+
+	ctx.Debugf("Read %d events, %d entities and %d silences", len(res.Events), len(res.Entities), len(res.Silences))
+	return res, nil
+}
+
+// LoadEvents : Read events offline from a file, see ReadEvents
+func LoadEvents(path string) ([]v2.Event, error) {
+	resources, err := LoadResources(path)
+	if err != nil {
+		return nil, err
+	}
+	return resources.Events, nil
+}
+
+// LoadResources : Read events, entities and silences offline from a file, see ReadResources
+func LoadResources(path string) (Resources, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Resources{}, err
+	}
+	defer f.Close()
+
+	resources, err := ReadResources(f)
+	if err != nil {
+		return resources, fmt.Errorf("%s: %w", path, err)
+	}
+	return resources, nil
+}
+
+// FilterNamespace : Keep the events of a namespace. Every event is kept when the namespace is empty
+func FilterNamespace(events []v2.Event, namespace string) []v2.Event {
+	if len(namespace) == 0 {
+		return events
+	}
+
+	filtered := []v2.Event{}
+	for _, evt := range events {
+		if eventNamespace(evt) == namespace {
+			filtered = append(filtered, evt)
+		}
+	}
+	return filtered
+}
+
+// firstByte : Peek the first non blank byte of the input, 0 when the input is blank
+func firstByte(br *bufio.Reader) (byte, error) {
+	for {
+		b, err := br.ReadByte()
+		if errors.Is(err, io.EOF) {
+			return 0, nil
+		} else if err != nil {
+			return 0, err
+		}
+		if strings.IndexByte(" \t\r\n", b) < 0 {
+			return b, br.UnreadByte()
+		}
+	}
+}
+
+// add : Add the resources held by a JSON value, an event, a wrapped resource or an array of those
+func (res *Resources) add(raw json.RawMessage) error {
+	if trimmed := bytes.TrimSpace(raw); len(trimmed) > 0 && trimmed[0] == '[' {
+		var values []json.RawMessage
+		if err := json.Unmarshal(raw, &values); err != nil {
+			return fmt.Errorf("invalid event list: %w", err)
+		}
+		for _, value := range values {
+			if err := res.add(value); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var wrapped wrappedResource
+	if err := json.Unmarshal(raw, &wrapped); err != nil {
+		return fmt.Errorf("invalid event: %w", err)
+	}
+
+	if wrapped.Spec == nil {
+		var evt v2.Event
+		if err := json.Unmarshal(raw, &evt); err != nil {
+			return fmt.Errorf("invalid event: %w", err)
+		}
+		res.Events = append(res.Events, evt)
+		return nil
+	}
+
+	switch wrapped.Type {
+	case "Event":
+		var evt v2.Event
+		if err := json.Unmarshal(wrapped.Spec, &evt); err != nil {
+			return fmt.Errorf("invalid event: %w", err)
+		}
+		if len(evt.Namespace) == 0 {
+			evt.ObjectMeta = wrapped.Metadata
+		}
+		res.Events = append(res.Events, evt)
+	case "Entity":
+		var entity v2.Entity
+		if err := json.Unmarshal(wrapped.Spec, &entity); err != nil {
+			return fmt.Errorf("invalid entity: %w", err)
+		}
+		if len(entity.Name) == 0 {
+			entity.ObjectMeta = wrapped.Metadata
+		}
+		res.Entities = append(res.Entities, entity)
+	case "Silenced":
+		var silence v2.Silenced
+		if err := json.Unmarshal(wrapped.Spec, &silence); err != nil {
+			return fmt.Errorf("invalid silence: %w", err)
+		}
+		if len(silence.Name) == 0 {
+			silence.ObjectMeta = wrapped.Metadata
+		}
+		res.Silences = append(res.Silences, silence)
+	default:
+		log.WithFields(log.Fields{
+			"file":     "sensu/input.go",
+			"function": "add",
+		}).Debugf("Skipping %s resource %s", wrapped.Type, wrapped.Metadata.Name)
+	}
+	return nil
+}
+
+// jsonValue : Convert a value decoded from YAML, whose mappings have interface{} keys, to a value
+// encoding/json can marshal
+func jsonValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		converted := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			converted[fmt.Sprint(key)] = jsonValue(item)
+		}
+		return converted
+	case []interface{}:
+		for i, item := range v {
+			v[i] = jsonValue(item)
+		}
+		return v
+	default:
+		return v
+	}
+}
@@ -0,0 +1,127 @@
+package sensu
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	corev2 "github.com/sensu/core/v2"
+	"github.com/sensu/sensu-plugin-sdk/sensu"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadEvents(t *testing.T) {
+	assert := assert.New(t)
+
+	evt1 := *corev2.FixtureEvent("localhost", "dummy-check1")
+	evt2 := *corev2.FixtureEvent("localhost2", "dummy-check1")
+	evt2.Check.Status = sensu.CheckStateCritical
+
+	list, err := json.Marshal([]corev2.Event{evt1, evt2})
+	assert.NoError(err)
+	var ndjson bytes.Buffer
+	enc := json.NewEncoder(&ndjson)
+	assert.NoError(enc.Encode(evt1))
+	assert.NoError(enc.Encode(evt2))
+	path := filepath.Join(t.TempDir(), "events.json")
+	assert.NoError(os.WriteFile(path, list, 0644))
+
+	for _, test := range []struct {
+		name string
+		read func() ([]corev2.Event, error)
+	}{
+		{"json", func() ([]corev2.Event, error) { return ReadEvents(bytes.NewReader(list)) }},
+		{"ndjson", func() ([]corev2.Event, error) { return ReadEvents(&ndjson) }},
+		{"file", func() ([]corev2.Event, error) { return LoadEvents(path) }},
+	} {
+		events, err := test.read()
+		assert.NoError(err, test.name)
+		assert.Len(events, 2, test.name)
+		assert.Equal("localhost2", events[1].Entity.Name, test.name)
+		assert.Equal(sensu.CheckStateCritical, GetEntitiesStatus(events)["localhost2"].Status, test.name)
+	}
+
+	_, err = LoadEvents(filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(err)
+}
+
+func TestReadEventsWrappedJSON(t *testing.T) {
+	assert := assert.New(t)
+
+	input := `{
+	"type": "Event",
+	"api_version": "core/v2",
+	"metadata": {"namespace": "production"},
+	"spec": {"entity": {"metadata": {"name": "web1"}}, "check": {"metadata": {"name": "http"}, "status": 2}}
+}
+{
+	"type": "CheckConfig",
+	"api_version": "core/v2",
+	"metadata": {"name": "http", "namespace": "production"},
+	"spec": {"command": "check-http"}
+}
+`
+
+	events, err := ReadEvents(strings.NewReader(input))
+	assert.NoError(err)
+	assert.Len(events, 1)
+	assert.Equal("web1", events[0].Entity.Name)
+	assert.Equal("production", events[0].Namespace)
+	assert.Equal(uint32(sensu.CheckStateCritical), events[0].Check.Status)
+}
+
+func TestReadEventsYAML(t *testing.T) {
+	assert := assert.New(t)
+
+	input := `type: Event
+api_version: core/v2
+metadata:
+  namespace: default
+spec:
+  entity:
+    metadata:
+      name: web1
+  check:
+    metadata:
+      name: http
+    status: 1
+---
+type: Event
+api_version: core/v2
+metadata:
+  namespace: production
+spec:
+  entity:
+    metadata:
+      name: db1
+  check:
+    metadata:
+      name: disk
+    status: 0
+`
+
+	events, err := ReadEvents(strings.NewReader(input))
+	assert.NoError(err)
+	assert.Len(events, 2)
+	assert.Equal(uint32(sensu.CheckStateWarning), events[0].Check.Status)
+	assert.Equal("db1", events[1].Entity.Name)
+
+	filtered := FilterNamespace(events, "production")
+	assert.Len(filtered, 1)
+	assert.Equal("db1", filtered[0].Entity.Name)
+	assert.Len(FilterNamespace(events, ""), 2)
+}
+
+func TestReadEventsInvalid(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := ReadEvents(strings.NewReader(`[{"entity": `))
+	assert.Error(err)
+
+	events, err := ReadEvents(strings.NewReader(" \n"))
+	assert.NoError(err)
+	assert.Empty(events)
+}
@@ -0,0 +1,595 @@
+package sensu
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// JSONPath : Compiled JSONPath template, following the kubectl syntax.
+// Missing keys produce no output instead of an error.
+//
+// Supported syntax:
+//
+//	{.items[*].name}                 field, index, wildcard and slice selection
+//	{.entities['web.example.com']}   quoted keys
+//	{..critical}                     recursive descent
+//	{.items[?(@.status==2)].name}    filters with ==, !=, <, <=, > and >=
+//	{range .items[*]}...{end}        iteration, paths are relative to the current item
+//	{"\t"}                           string literals
+type JSONPath struct {
+	nodes []jsonPathNode
+}
+
+type jsonPathNodeKind int
+
+const (
+	nodeText jsonPathNodeKind = iota
+	nodePath
+	nodeRange
+)
+
+type jsonPathNode struct {
+	kind jsonPathNodeKind
+	text string
+	path jsonPathExpr
+	body []jsonPathNode
+}
+
+// jsonPathExpr : Path expression, evaluated from the root document when absolute
+type jsonPathExpr struct {
+	absolute bool
+	steps    []jsonPathStep
+}
+
+type jsonPathStepKind int
+
+const (
+	stepField jsonPathStepKind = iota
+	stepRecursive
+	stepWildcard
+	stepIndex
+	stepSlice
+	stepFilter
+)
+
+type jsonPathStep struct {
+	kind   jsonPathStepKind
+	field  string
+	index  int
+	start  *int
+	end    *int
+	filter *jsonPathFilter
+}
+
+type jsonPathFilter struct {
+	left  jsonPathExpr
+	op    string
+	right interface{}
+}
+
+// ParseJSONPath : Compile a JSONPath template
+func ParseJSONPath(text string) (*JSONPath, error) {
+	p := &jsonPathParser{text: text}
+	nodes, err := p.parseNodes(false)
+	if err != nil {
+		return nil, fmt.Errorf("jsonpath: %v", err)
+	}
+	return &JSONPath{nodes: nodes}, nil
+}
+
+// Execute : Render the template over a JSON document as decoded by encoding/json
+func (j *JSONPath) Execute(w io.Writer, doc interface{}) error {
+	return executeJSONPathNodes(w, j.nodes, doc, doc)
+}
+
+type jsonPathParser struct {
+	text string
+	pos  int
+}
+
+// parseNodes : Parse text and expressions until the end of the input, or until {end} when inRange
+func (p *jsonPathParser) parseNodes(inRange bool) ([]jsonPathNode, error) {
+	var nodes []jsonPathNode
+
+	for p.pos < len(p.text) {
+		open := strings.IndexByte(p.text[p.pos:], '{')
+		if open < 0 {
+			nodes = append(nodes, jsonPathNode{kind: nodeText, text: p.text[p.pos:]})
+			p.pos = len(p.text)
+			break
+		}
+		if open > 0 {
+			nodes = append(nodes, jsonPathNode{kind: nodeText, text: p.text[p.pos : p.pos+open]})
+		}
+		p.pos += open
+
+		closing := matchingBrace(p.text, p.pos)
+		if closing < 0 {
+			return nil, fmt.Errorf("unclosed expression %q", p.text[p.pos:])
+		}
+		expr := strings.TrimSpace(p.text[p.pos+1 : closing])
+		p.pos = closing + 1
+
+		switch {
+		case expr == "end":
+			if !inRange {
+				return nil, fmt.Errorf("{end} without {range}")
+			}
+			return nodes, nil
+		case strings.HasPrefix(expr, "range "):
+			path, err := parseJSONPathExpr(strings.TrimSpace(strings.TrimPrefix(expr, "range ")))
+			if err != nil {
+				return nil, err
+			}
+			body, err := p.parseNodes(true)
+			if err != nil {
+				return nil, err
+			}
+			nodes = append(nodes, jsonPathNode{kind: nodeRange, path: path, body: body})
+		case strings.HasPrefix(expr, `"`):
+			literal, err := strconv.Unquote(expr)
+			if err != nil {
+				return nil, fmt.Errorf("invalid string literal %s", expr)
+			}
+			nodes = append(nodes, jsonPathNode{kind: nodeText, text: literal})
+		default:
+			path, err := parseJSONPathExpr(expr)
+			if err != nil {
+				return nil, err
+			}
+			nodes = append(nodes, jsonPathNode{kind: nodePath, path: path})
+		}
+	}
+
+	if inRange {
+		return nil, fmt.Errorf("{range} without {end}")
+	}
+	return nodes, nil
+}
+
+// matchingBrace : Position of the brace closing the one at open, ignoring braces in quotes
+func matchingBrace(text string, open int) int {
+	var quote byte
+	for i := open + 1; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote != 0 && c == '\\':
+			i++
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '}':
+			return i
+		}
+	}
+	return -1
+}
+
+// parseJSONPathExpr : Parse a path such as $.items[0].name, .name or @.status
+func parseJSONPathExpr(expr string) (jsonPathExpr, error) {
+	var path jsonPathExpr
+
+	if strings.HasPrefix(expr, "$") {
+		path.absolute = true
+		expr = expr[1:]
+	} else if strings.HasPrefix(expr, "@") {
+		expr = expr[1:]
+	}
+
+	for len(expr) > 0 {
+		switch {
+		case strings.HasPrefix(expr, ".."):
+			name, rest := splitJSONPathField(expr[2:])
+			if len(name) == 0 {
+				return path, fmt.Errorf("missing field name after '..' in %q", expr)
+			}
+			path.steps = append(path.steps, jsonPathStep{kind: stepRecursive, field: name})
+			expr = rest
+		case strings.HasPrefix(expr, ".*"):
+			path.steps = append(path.steps, jsonPathStep{kind: stepWildcard})
+			expr = expr[2:]
+		case strings.HasPrefix(expr, "."):
+			name, rest := splitJSONPathField(expr[1:])
+			if len(name) > 0 {
+				path.steps = append(path.steps, jsonPathStep{kind: stepField, field: name})
+			}
+			expr = rest
+		case strings.HasPrefix(expr, "["):
+			closing := matchingBracket(expr)
+			if closing < 0 {
+				return path, fmt.Errorf("unclosed '[' in %q", expr)
+			}
+			step, err := parseJSONPathBracket(strings.TrimSpace(expr[1:closing]))
+			if err != nil {
+				return path, err
+			}
+			path.steps = append(path.steps, step)
+			expr = expr[closing+1:]
+		default:
+			return path, fmt.Errorf("unexpected %q", expr)
+		}
+	}
+
+	return path, nil
+}
+
+// splitJSONPathField : Split a field name from the rest of a path
+func splitJSONPathField(expr string) (string, string) {
+	end := strings.IndexAny(expr, ".[")
+	if end < 0 {
+		return expr, ""
+	}
+	return expr[:end], expr[end:]
+}
+
+// matchingBracket : Position of the bracket closing the one starting expr, ignoring brackets in quotes
+func matchingBracket(expr string) int {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(expr); i++ {
+		c := expr[i]
+		switch {
+		case quote != 0 && c == '\\':
+			i++
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// parseJSONPathBracket : Parse the content of a [...] selector
+func parseJSONPathBracket(content string) (jsonPathStep, error) {
+	switch {
+	case content == "*":
+		return jsonPathStep{kind: stepWildcard}, nil
+	case strings.HasPrefix(content, "'") || strings.HasPrefix(content, `"`):
+		name, err := unquoteJSONPath(content)
+		if err != nil {
+			return jsonPathStep{}, err
+		}
+		return jsonPathStep{kind: stepField, field: name}, nil
+	case strings.HasPrefix(content, "?(") && strings.HasSuffix(content, ")"):
+		filter, err := parseJSONPathFilter(strings.TrimSpace(content[2 : len(content)-1]))
+		if err != nil {
+			return jsonPathStep{}, err
+		}
+		return jsonPathStep{kind: stepFilter, filter: filter}, nil
+	case strings.Contains(content, ":"):
+		bounds := strings.SplitN(content, ":", 2)
+		step := jsonPathStep{kind: stepSlice}
+		for i, bound := range bounds {
+			bound = strings.TrimSpace(bound)
+			if len(bound) == 0 {
+				continue
+			}
+			value, err := strconv.Atoi(bound)
+			if err != nil {
+				return jsonPathStep{}, fmt.Errorf("invalid slice bound %q", bound)
+			}
+			if i == 0 {
+				step.start = &value
+			} else {
+				step.end = &value
+			}
+		}
+		return step, nil
+	default:
+		index, err := strconv.Atoi(content)
+		if err != nil {
+			return jsonPathStep{}, fmt.Errorf("invalid index %q", content)
+		}
+		return jsonPathStep{kind: stepIndex, index: index}, nil
+	}
+}
+
+func unquoteJSONPath(text string) (string, error) {
+	if strings.HasPrefix(text, "'") {
+		if len(text) < 2 || !strings.HasSuffix(text, "'") {
+			return "", fmt.Errorf("invalid quoted string %s", text)
+		}
+		return strings.ReplaceAll(text[1:len(text)-1], `\'`, "'"), nil
+	}
+	value, err := strconv.Unquote(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid quoted string %s", text)
+	}
+	return value, nil
+}
+
+// jsonPathOperators : Filter operators, two characters operators first
+var jsonPathOperators = []string{"==", "!=", "<=", ">=", "<", ">"}
+
+// parseJSONPathFilter : Parse a filter such as @.status==2, or @.name for an existence test
+func parseJSONPathFilter(content string) (*jsonPathFilter, error) {
+	if !strings.HasPrefix(content, "@") {
+		return nil, fmt.Errorf("filter %q must start with @", content)
+	}
+
+	filter := &jsonPathFilter{}
+	left := content
+	if i, op := indexJSONPathOperator(content); i >= 0 {
+		filter.op = op
+		left = strings.TrimSpace(content[:i])
+		right := strings.TrimSpace(content[i+len(op):])
+		value, err := parseJSONPathLiteral(right)
+		if err != nil {
+			return nil, err
+		}
+		filter.right = value
+	}
+
+	path, err := parseJSONPathExpr(left)
+	if err != nil {
+		return nil, err
+	}
+	filter.left = path
+	return filter, nil
+}
+
+// indexJSONPathOperator : Position of the first filter operator outside quoted strings, -1 if none
+func indexJSONPathOperator(content string) (int, string) {
+	var quote byte
+	for i := 0; i < len(content); i++ {
+		c := content[i]
+		if quote != 0 {
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+			continue
+		}
+		if c == '\'' || c == '"' {
+			quote = c
+			continue
+		}
+		for _, op := range jsonPathOperators {
+			if strings.HasPrefix(content[i:], op) {
+				return i, op
+			}
+		}
+	}
+	return -1, ""
+}
+
+// parseJSONPathLiteral : Parse a filter operand, a number, quoted string, boolean or null
+func parseJSONPathLiteral(text string) (interface{}, error) {
+	switch text {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	}
+	if strings.HasPrefix(text, "'") || strings.HasPrefix(text, `"`) {
+		return unquoteJSONPath(text)
+	}
+	value, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter operand %q", text)
+	}
+	return value, nil
+}
+
+func executeJSONPathNodes(w io.Writer, nodes []jsonPathNode, root interface{}, current interface{}) error {
+	for _, node := range nodes {
+		switch node.kind {
+		case nodeText:
+			if _, err := io.WriteString(w, node.text); err != nil {
+				return err
+			}
+		case nodePath:
+			var texts []string
+			for _, value := range evalJSONPath(node.path, root, current) {
+				text, err := formatJSONPathValue(value)
+				if err != nil {
+					return err
+				}
+				texts = append(texts, text)
+			}
+			if _, err := io.WriteString(w, strings.Join(texts, " ")); err != nil {
+				return err
+			}
+		case nodeRange:
+			items := evalJSONPath(node.path, root, current)
+			if len(items) == 1 {
+				if list, ok := items[0].([]interface{}); ok {
+					items = list
+				}
+			}
+			for _, item := range items {
+				if err := executeJSONPathNodes(w, node.body, root, item); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func formatJSONPathValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	default:
+		data, err := json.Marshal(v)
+		return string(data), err
+	}
+}
+
+// evalJSONPath : Evaluate a path, returning every matching value
+func evalJSONPath(path jsonPathExpr, root interface{}, current interface{}) []interface{} {
+	values := []interface{}{current}
+	if path.absolute {
+		values = []interface{}{root}
+	}
+
+	for _, step := range path.steps {
+		var next []interface{}
+		for _, value := range values {
+			next = append(next, evalJSONPathStep(step, root, value)...)
+		}
+		values = next
+	}
+
+	return values
+}
+
+func evalJSONPathStep(step jsonPathStep, root interface{}, value interface{}) []interface{} {
+	switch step.kind {
+	case stepField:
+		if m, ok := value.(map[string]interface{}); ok {
+			if v, ok := m[step.field]; ok {
+				return []interface{}{v}
+			}
+		}
+	case stepRecursive:
+		var found []interface{}
+		walkJSONPath(value, func(m map[string]interface{}) {
+			if v, ok := m[step.field]; ok {
+				found = append(found, v)
+			}
+		})
+		return found
+	case stepWildcard:
+		return jsonPathChildren(value)
+	case stepIndex:
+		if list, ok := value.([]interface{}); ok {
+			index := step.index
+			if index < 0 {
+				index += len(list)
+			}
+			if index >= 0 && index < len(list) {
+				return []interface{}{list[index]}
+			}
+		}
+	case stepSlice:
+		if list, ok := value.([]interface{}); ok {
+			start, end := 0, len(list)
+			if step.start != nil {
+				start = *step.start
+			}
+			if step.end != nil {
+				end = *step.end
+			}
+			if start < 0 {
+				start += len(list)
+			}
+			if end < 0 {
+				end += len(list)
+			}
+			start = min(max(start, 0), len(list))
+			end = min(max(end, start), len(list))
+			return list[start:end]
+		}
+	case stepFilter:
+		var found []interface{}
+		for _, child := range jsonPathChildren(value) {
+			if step.filter.match(root, child) {
+				found = append(found, child)
+			}
+		}
+		return found
+	}
+	return nil
+}
+
+// jsonPathChildren : Elements of a list, or values of an object sorted by key
+func jsonPathChildren(value interface{}) []interface{} {
+	switch v := value.(type) {
+	case []interface{}:
+		return v
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		children := make([]interface{}, 0, len(v))
+		for _, key := range keys {
+			children = append(children, v[key])
+		}
+		return children
+	}
+	return nil
+}
+
+// walkJSONPath : Call fn on every object of a document, depth first
+func walkJSONPath(value interface{}, fn func(map[string]interface{})) {
+	if m, ok := value.(map[string]interface{}); ok {
+		fn(m)
+	}
+	for _, child := range jsonPathChildren(value) {
+		walkJSONPath(child, fn)
+	}
+}
+
+func (f *jsonPathFilter) match(root interface{}, value interface{}) bool {
+	found := evalJSONPath(f.left, root, value)
+	if len(f.op) == 0 {
+		return len(found) > 0
+	}
+	if len(found) == 0 {
+		return false
+	}
+
+	left := found[0]
+	switch f.op {
+	case "==":
+		return left == f.right
+	case "!=":
+		return left != f.right
+	}
+
+	var order int
+	switch l := left.(type) {
+	case float64:
+		r, ok := f.right.(float64)
+		if !ok {
+			return false
+		}
+		order = cmp.Compare(l, r)
+	case string:
+		r, ok := f.right.(string)
+		if !ok {
+			return false
+		}
+		order = cmp.Compare(l, r)
+	default:
+		return false
+	}
+
+	switch f.op {
+	case "<":
+		return order < 0
+	case "<=":
+		return order <= 0
+	case ">":
+		return order > 0
+	default:
+		return order >= 0
+	}
+}
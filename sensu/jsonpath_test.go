@@ -0,0 +1,82 @@
+package sensu
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const jsonPathFixture = `{
+	"metadata": {"namespace": "default"},
+	"entities": {
+		"web.example.com": {"status": 2, "critical": 1},
+		"localhost": {"status": 0, "critical": 0}
+	},
+	"items": [
+		{"name": "web.example.com", "status": 2, "silenced": false},
+		{"name": "db1", "status": 1, "silenced": true},
+		{"name": "localhost", "status": 0, "silenced": false}
+	]
+}`
+
+func executeJSONPathFixture(t *testing.T, template string) string {
+	var doc interface{}
+	assert.NoError(t, json.Unmarshal([]byte(jsonPathFixture), &doc))
+
+	path, err := ParseJSONPath(template)
+	assert.NoError(t, err)
+
+	var buf bytes.Buffer
+	assert.NoError(t, path.Execute(&buf, doc))
+	return buf.String()
+}
+
+func TestJSONPath(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := map[string]string{
+		`{.metadata.namespace}`:                              "default",
+		`ns={$.metadata.namespace}!`:                         "ns=default!",
+		`{.items[*].name}`:                                   "web.example.com db1 localhost",
+		`{.items[0].name}`:                                   "web.example.com",
+		`{.items[-1].name}`:                                  "localhost",
+		`{.items[0:2].status}`:                               "2 1",
+		`{.items[1:].name}`:                                  "db1 localhost",
+		`{.entities['web.example.com'].status}`:              "2",
+		`{.entities.*.status}`:                               "0 2",
+		`{..critical}`:                                       "0 1",
+		`{.items[?(@.status==2)].name}`:                      "web.example.com",
+		`{.items[?(@.status>=1)].name}`:                      "web.example.com db1",
+		`{.items[?(@.name!='db1')].status}`:                  "2 0",
+		`{.items[?(@.silenced==true)].name}`:                 "db1",
+		`{.items[?(@.missing)].name}`:                        "",
+		`{.items[?(@.name!='a==b')].name}`:                   "web.example.com db1 localhost",
+		`{.items[?(@.name=="db1<2")].name}`:                  "",
+		`{.missing.key}`:                                     "",
+		`{.metadata}`:                                        `{"namespace":"default"}`,
+		`{range .items[*]}{.name}{"\t"}{.status}{"\n"}{end}`: "web.example.com\t2\ndb1\t1\nlocalhost\t0\n",
+		`{range .items}[{.name}]{end}`:                       "[web.example.com][db1][localhost]",
+	}
+
+	for template, expected := range tests {
+		assert.Equal(expected, executeJSONPathFixture(t, template), template)
+	}
+}
+
+func TestParseJSONPathErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, template := range []string{
+		`{.items[*].name`,
+		`{range .items[*]}{.name}`,
+		`{.name}{end}`,
+		`{.items[abc]}`,
+		`{.items[?(status==1)]}`,
+		`{"unterminated}`,
+	} {
+		_, err := ParseJSONPath(template)
+		assert.Error(err, template)
+	}
+}
@@ -0,0 +1,139 @@
+package sensu
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/apex/log"
+	v2 "github.com/sensu/core/v2"
+	"github.com/sensu/sensu-plugin-sdk/sensu"
+)
+
+// DefaultJUnitFailOn : Check statuses reported as failures by the junit output
+var DefaultJUnitFailOn = []int{sensu.CheckStateCritical, sensu.CheckStateWarning}
+
+// JUnitOptions : Options of the junit output
+type JUnitOptions struct {
+	// FailOn are the check statuses reported as failures, DefaultJUnitFailOn when empty
+	FailOn []int
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Output  string `xml:",chardata"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr"`
+}
+
+// WriteJUnitResult : Write the entities status as a JUnit XML report.
+// Every entity is a testsuite and every event a testcase. Events in one of the FailOn statuses are
+// failures carrying the check output, silenced events are skipped
+func WriteJUnitResult(w io.Writer, events []v2.Event, namespace string, ts time.Time, opts JUnitOptions) error {
+	ctx := log.WithFields(log.Fields{
+		"file":     "sensu/junit.go",
+		"function": "WriteJUnitResult",
+	})
+
+	failOn := opts.FailOn
+	if len(failOn) == 0 {
+		failOn = DefaultJUnitFailOn
+	}
+
+	suites := make(map[string]*junitTestSuite)
+	for _, evt := range events {
+		if evt.Entity == nil || evt.Check == nil {
+			continue
+		}
+
+		suite, ok := suites[evt.Entity.Name]
+		if !ok {
+			suite = &junitTestSuite{Name: evt.Entity.Name, Timestamp: ts.UTC().Format(time.RFC3339)}
+			suites[evt.Entity.Name] = suite
+		}
+
+		testCase := junitTestCase{
+			Name:      evt.Check.Name,
+			ClassName: namespace + "." + evt.Entity.Name,
+			Time:      fmt.Sprintf("%.3f", evt.Check.Duration),
+		}
+		status := int(evt.Check.Status)
+		if evt.IsSilenced() {
+			testCase.Skipped = &junitSkipped{Message: "silenced by " + strings.Join(evt.Check.Silenced, ", ")}
+			suite.Skipped++
+		} else if slices.Contains(failOn, status) {
+			message, _, _ := strings.Cut(strings.TrimSpace(evt.Check.Output), "\n")
+			testCase.Failure = &junitFailure{
+				Message: message,
+				Type:    translateStatus(status),
+				Output:  evt.Check.Output,
+			}
+			suite.Failures++
+		} else {
+			testCase.SystemOut = evt.Check.Output
+		}
+		suite.Tests++
+		suite.Cases = append(suite.Cases, testCase)
+	}
+
+	report := junitTestSuites{Name: "entities-status " + namespace}
+	entities := make([]string, 0, len(suites))
+	for entity := range suites {
+		entities = append(entities, entity)
+	}
+	sort.Strings(entities)
+	for _, entity := range entities {
+		suite := suites[entity]
+		sort.SliceStable(suite.Cases, func(i, j int) bool { return suite.Cases[i].Name < suite.Cases[j].Name })
+		report.Tests += suite.Tests
+		report.Failures += suite.Failures
+		report.Skipped += suite.Skipped
+		report.Suites = append(report.Suites, *suite)
+	}
+
+	ctx.Debugf("Writing %d testsuites, %d testcases", len(report.Suites), report.Tests)
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
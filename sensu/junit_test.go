@@ -0,0 +1,61 @@
+package sensu
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	corev2 "github.com/sensu/core/v2"
+	"github.com/sensu/sensu-plugin-sdk/sensu"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteJUnitResult(t *testing.T) {
+	assert := assert.New(t)
+
+	disk := corev2.FixtureEvent("web", "disk")
+	disk.Check.Status = sensu.CheckStateCritical
+	disk.Check.Output = "DISK CRITICAL - / is 97% full\n/var is 80% full"
+	disk.Check.Duration = 0.25
+	cpu := corev2.FixtureEvent("web", "cpu")
+	cpu.Check.Status = sensu.CheckStateWarning
+	cpu.Check.Output = "CPU WARNING - load 4.2"
+	cpu.Check.Silenced = []string{"web:cpu"}
+	ntp := corev2.FixtureEvent("db", "ntp")
+	ntp.Check.Status = sensu.CheckStateUnknown
+	ntp.Check.Output = "NTP UNKNOWN"
+	mem := corev2.FixtureEvent("db", "mem")
+	mem.Check.Output = "MEM OK"
+	events := []corev2.Event{*disk, *cpu, *ntp, *mem}
+
+	var buf bytes.Buffer
+	assert.NoError(WriteJUnitResult(&buf, events, "default", time.Unix(1700000000, 0), JUnitOptions{}))
+	assert.Equal(`<?xml version="1.0" encoding="UTF-8"?>
+<testsuites name="entities-status default" tests="4" failures="1" skipped="1">
+  <testsuite name="db" tests="2" failures="0" skipped="0" timestamp="2023-11-14T22:13:20Z">
+    <testcase name="mem" classname="default.db" time="1.000">
+      <system-out>MEM OK</system-out>
+    </testcase>
+    <testcase name="ntp" classname="default.db" time="1.000">
+      <system-out>NTP UNKNOWN</system-out>
+    </testcase>
+  </testsuite>
+  <testsuite name="web" tests="2" failures="1" skipped="1" timestamp="2023-11-14T22:13:20Z">
+    <testcase name="cpu" classname="default.web" time="1.000">
+      <skipped message="silenced by web:cpu"></skipped>
+    </testcase>
+    <testcase name="disk" classname="default.web" time="0.250">
+      <failure message="DISK CRITICAL - / is 97% full" type="CRIT">DISK CRITICAL - / is 97% full&#xA;/var is 80% full</failure>
+    </testcase>
+  </testsuite>
+</testsuites>
+`, buf.String())
+
+	// Unknown checks reported as failures
+	buf.Reset()
+	assert.NoError(WriteJUnitResult(&buf, events, "default", time.Unix(1700000000, 0), JUnitOptions{
+		FailOn: []int{sensu.CheckStateCritical, sensu.CheckStateUnknown},
+	}))
+	assert.Contains(buf.String(), `<testsuites name="entities-status default" tests="4" failures="2" skipped="1">`)
+	assert.Contains(buf.String(), `<failure message="NTP UNKNOWN" type="UNKN">NTP UNKNOWN</failure>`)
+}
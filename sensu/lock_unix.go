@@ -0,0 +1,20 @@
+//go:build linux || darwin
+
+package sensu
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// lockFile : Take an exclusive lock of the file, waiting for it to be released by other processes.
+// The lock is released when the file is closed
+func lockFile(f *os.File) error {
+	for {
+		err := unix.Flock(int(f.Fd()), unix.LOCK_EX)
+		if err != unix.EINTR {
+			return err
+		}
+	}
+}
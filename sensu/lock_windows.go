@@ -0,0 +1,13 @@
+package sensu
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// lockFile : Take an exclusive lock of the file, waiting for it to be released by other processes.
+// The lock is released when the file is closed
+func lockFile(f *os.File) error {
+	return windows.LockFileEx(windows.Handle(f.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK, 0, 1, 0, &windows.Overlapped{})
+}
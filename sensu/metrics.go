@@ -0,0 +1,120 @@
+package sensu
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/apex/log"
+)
+
+// influxTagEscaper : Escape tag keys and values as required by the line protocol
+var influxTagEscaper = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+
+// graphiteNodeEscaper : Replace characters having a meaning in Graphite metric paths
+var graphiteNodeEscaper = strings.NewReplacer(".", "_", " ", "_", "/", "_")
+
+// statusField : Name and value of an EntityStatus counter
+type statusField struct {
+	Name  string
+	Value int
+}
+
+// statusFields : Name and value of every EntityStatus counter, in output order
+func statusFields(status EntityStatus) []statusField {
+	return []statusField{
+		{"status", status.Status},
+		{"silenced", status.Silenced},
+		{"critical", status.Critical},
+		{"warning", status.Warning},
+		{"unknown", status.Unknown},
+		{"ok", status.Ok},
+		{"total", status.Total},
+	}
+}
+
+func sortedEntities(statusMap map[string]EntityStatus) []string {
+	entities := make([]string, 0, len(statusMap))
+	for entity := range statusMap {
+		entities = append(entities, entity)
+	}
+	sort.Strings(entities)
+	return entities
+}
+
+// influxTags : Namespace and cluster tags of the points, when set, with their leading comma.
+// Line protocol does not allow empty tag values
+func influxTags(namespace string, cluster string) string {
+	var tags string
+	if len(namespace) > 0 {
+		tags += ",namespace=" + influxTagEscaper.Replace(namespace)
+	}
+	if len(cluster) > 0 {
+		tags += ",cluster=" + influxTagEscaper.Replace(cluster)
+	}
+	return tags
+}
+
+// graphitePrefix : sensu.[cluster.][namespace.] prefix of the metric paths, without empty nodes
+func graphitePrefix(namespace string, cluster string) string {
+	prefix := "sensu."
+	if len(cluster) > 0 {
+		prefix += graphiteNodeEscaper.Replace(cluster) + "."
+	}
+	if len(namespace) > 0 {
+		prefix += graphiteNodeEscaper.Replace(namespace) + "."
+	}
+	return prefix
+}
+
+// WriteInfluxLines : Write the entities status in InfluxDB line protocol.
+// Every entity is a sensu_entity point tagged with namespace and cluster (when set) and entity
+func WriteInfluxLines(w io.Writer, statusMap map[string]EntityStatus, namespace string, cluster string, ts time.Time) error {
+	ctx := log.WithFields(log.Fields{
+		"file":     "sensu/metrics.go",
+		"function": "WriteInfluxLines",
+	})
+
+	tags := influxTags(namespace, cluster)
+
+	ctx.Debugf("Writing %d points", len(statusMap))
+	bw := bufio.NewWriter(w)
+	for _, entity := range sortedEntities(statusMap) {
+		var fields []string
+		for _, field := range statusFields(statusMap[entity]) {
+			fields = append(fields, fmt.Sprintf("%s=%di", field.Name, field.Value))
+		}
+		fmt.Fprintf(bw, "sensu_entity%s,entity=%s %s %d\n",
+			tags,
+			influxTagEscaper.Replace(entity),
+			strings.Join(fields, ","),
+			ts.UnixNano(),
+		)
+	}
+
+	return bw.Flush()
+}
+
+// WriteGraphiteLines : Write the entities status in Graphite plaintext protocol.
+// Metric paths are sensu.[cluster.][namespace.]entity.counter
+func WriteGraphiteLines(w io.Writer, statusMap map[string]EntityStatus, namespace string, cluster string, ts time.Time) error {
+	ctx := log.WithFields(log.Fields{
+		"file":     "sensu/metrics.go",
+		"function": "WriteGraphiteLines",
+	})
+
+	prefix := graphitePrefix(namespace, cluster)
+
+	ctx.Debugf("Writing metrics for %d entities", len(statusMap))
+	bw := bufio.NewWriter(w)
+	for _, entity := range sortedEntities(statusMap) {
+		for _, field := range statusFields(statusMap[entity]) {
+			fmt.Fprintf(bw, "%s%s.%s %d %d\n", prefix, graphiteNodeEscaper.Replace(entity), field.Name, field.Value, ts.Unix())
+		}
+	}
+
+	return bw.Flush()
+}
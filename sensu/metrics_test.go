@@ -0,0 +1,61 @@
+package sensu
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/sensu/sensu-plugin-sdk/sensu"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteInfluxLines(t *testing.T) {
+	assert := assert.New(t)
+
+	statusMap := map[string]EntityStatus{
+		"web 1":     {Status: sensu.CheckStateCritical, Critical: 1, Ok: 2, Total: 3},
+		"localhost": {Status: sensu.CheckStateOK, Ok: 1, Total: 1},
+	}
+	ts := time.Unix(1700000000, 0)
+
+	var buf bytes.Buffer
+	assert.NoError(WriteInfluxLines(&buf, statusMap, "default", "prod", ts))
+	assert.Equal(
+		"sensu_entity,namespace=default,cluster=prod,entity=localhost status=0i,silenced=0i,critical=0i,warning=0i,unknown=0i,ok=1i,total=1i 1700000000000000000\n"+
+			"sensu_entity,namespace=default,cluster=prod,entity=web\\ 1 status=2i,silenced=0i,critical=1i,warning=0i,unknown=0i,ok=2i,total=3i 1700000000000000000\n",
+		buf.String(),
+	)
+
+	// Empty tag values are not allowed by the line protocol
+	buf.Reset()
+	assert.NoError(WriteInfluxLines(&buf, statusMap, "default", "", ts))
+	assert.NotContains(buf.String(), "cluster=")
+
+	buf.Reset()
+	assert.NoError(WriteInfluxLines(&buf, statusMap, "", "", ts))
+	assert.Contains(buf.String(), "sensu_entity,entity=localhost status=0i,")
+}
+
+func TestWriteGraphiteLines(t *testing.T) {
+	assert := assert.New(t)
+
+	statusMap := map[string]EntityStatus{
+		"web.example.com": {Status: sensu.CheckStateWarning, Warning: 1, Total: 1},
+	}
+	ts := time.Unix(1700000000, 0)
+
+	var buf bytes.Buffer
+	assert.NoError(WriteGraphiteLines(&buf, statusMap, "default", "prod", ts))
+	assert.Contains(buf.String(), "sensu.prod.default.web_example_com.status 1 1700000000\n")
+	assert.Contains(buf.String(), "sensu.prod.default.web_example_com.warning 1 1700000000\n")
+	assert.Equal(7, bytes.Count(buf.Bytes(), []byte("\n")))
+
+	buf.Reset()
+	assert.NoError(WriteGraphiteLines(&buf, statusMap, "default", "", ts))
+	assert.Contains(buf.String(), "sensu.default.web_example_com.total 1 1700000000\n")
+
+	// Nor are empty nodes by Graphite
+	buf.Reset()
+	assert.NoError(WriteGraphiteLines(&buf, statusMap, "", "", ts))
+	assert.Contains(buf.String(), "sensu.web_example_com.total 1 1700000000\n")
+}
@@ -0,0 +1,62 @@
+package sensu
+
+import (
+	"strconv"
+
+	"github.com/apex/log"
+	v2 "github.com/sensu/core/v2"
+)
+
+// AnnotationPrefix : Prefix used by every annotation added by the mutator
+const AnnotationPrefix = "entities-status/"
+
+// MergeEvent : Replace in a list of events the one matching the same entity and check.
+// The event is appended if no matching event is found
+func MergeEvent(events []v2.Event, event v2.Event) []v2.Event {
+	ctx := log.WithFields(log.Fields{
+		"file":     "sensu/mutator.go",
+		"function": "MergeEvent",
+	})
+
+	if event.Entity == nil || event.Check == nil {
+		return events
+	}
+
+	for i, evt := range events {
+		if evt.Entity == nil || evt.Check == nil {
+			continue
+		}
+		if evt.Entity.Name == event.Entity.Name && evt.Check.Name == event.Check.Name {
+			ctx.Debugf("Replacing event %s/%s", event.Entity.Name, event.Check.Name)
+			events[i] = event
+			return events
+		}
+	}
+
+	ctx.Debugf("Adding event %s/%s", event.Entity.Name, event.Check.Name)
+	return append(events, event)
+}
+
+// AnnotateEvent : Attach the entity rollup status to the event annotations
+func AnnotateEvent(event *v2.Event, status EntityStatus) {
+	if event.ObjectMeta.Annotations == nil {
+		event.ObjectMeta.Annotations = make(map[string]string)
+	}
+
+	annotations := map[string]int{
+		"status":         status.Status,
+		"silenced":       status.Silenced,
+		"critical":       status.Critical,
+		"warning":        status.Warning,
+		"unknown":        status.Unknown,
+		"ok":             status.Ok,
+		"total":          status.Total,
+		"failing-streak": status.FailingStreak,
+	}
+	for key, value := range annotations {
+		event.ObjectMeta.Annotations[AnnotationPrefix+key] = strconv.Itoa(value)
+	}
+	event.ObjectMeta.Annotations[AnnotationPrefix+"state"] = translateStatus(status.Status)
+	event.ObjectMeta.Annotations[AnnotationPrefix+"failure-ratio"] = strconv.FormatFloat(status.FailureRatio, 'f', 3, 64)
+	event.ObjectMeta.Annotations[AnnotationPrefix+"recovered"] = strconv.FormatBool(status.Recovered)
+}
@@ -0,0 +1,62 @@
+package sensu
+
+import (
+	"testing"
+
+	corev2 "github.com/sensu/core/v2"
+	"github.com/sensu/sensu-plugin-sdk/sensu"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeEvent(t *testing.T) {
+	assert := assert.New(t)
+
+	var eventList []corev2.Event = []corev2.Event{}
+	eventList = append(eventList, *corev2.FixtureEvent("localhost", "dummy-check1"))
+	eventList = append(eventList, *corev2.FixtureEvent("localhost", "dummy-check2"))
+
+	// Same entity and check, the stored event is replaced
+	evt := *corev2.FixtureEvent("localhost", "dummy-check2")
+	evt.Check.Status = sensu.CheckStateCritical
+	eventList = MergeEvent(eventList, evt)
+	assert.Len(eventList, 2)
+	assert.Equal(uint32(sensu.CheckStateCritical), eventList[1].Check.Status)
+
+	// New check, the event is appended
+	eventList = MergeEvent(eventList, *corev2.FixtureEvent("localhost", "dummy-check3"))
+	assert.Len(eventList, 3)
+
+	// Events without check are ignored
+	eventList = MergeEvent(eventList, corev2.Event{})
+	assert.Len(eventList, 3)
+}
+
+func TestAnnotateEvent(t *testing.T) {
+	assert := assert.New(t)
+
+	evt := corev2.FixtureEvent("localhost", "dummy-check1")
+	evt.ObjectMeta.Annotations = nil
+
+	AnnotateEvent(evt, EntityStatus{
+		Status:        sensu.CheckStateCritical,
+		Silenced:      1,
+		Critical:      2,
+		Warning:       3,
+		Ok:            4,
+		Total:         9,
+		FailureRatio:  0.25,
+		FailingStreak: 4,
+	})
+
+	assert.Equal("2", evt.ObjectMeta.Annotations["entities-status/status"])
+	assert.Equal("CRIT", evt.ObjectMeta.Annotations["entities-status/state"])
+	assert.Equal("1", evt.ObjectMeta.Annotations["entities-status/silenced"])
+	assert.Equal("2", evt.ObjectMeta.Annotations["entities-status/critical"])
+	assert.Equal("3", evt.ObjectMeta.Annotations["entities-status/warning"])
+	assert.Equal("0", evt.ObjectMeta.Annotations["entities-status/unknown"])
+	assert.Equal("4", evt.ObjectMeta.Annotations["entities-status/ok"])
+	assert.Equal("9", evt.ObjectMeta.Annotations["entities-status/total"])
+	assert.Equal("0.250", evt.ObjectMeta.Annotations["entities-status/failure-ratio"])
+	assert.Equal("4", evt.ObjectMeta.Annotations["entities-status/failing-streak"])
+	assert.Equal("false", evt.ObjectMeta.Annotations["entities-status/recovered"])
+}
@@ -0,0 +1,108 @@
+package sensu
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/apex/log"
+	"github.com/sensu/sensu-plugin-sdk/sensu"
+)
+
+// nagiosStateNames : Nagios plugin state of each check status
+var nagiosStateNames = map[int]string{
+	sensu.CheckStateOK:       "OK",
+	sensu.CheckStateWarning:  "WARNING",
+	sensu.CheckStateCritical: "CRITICAL",
+	sensu.CheckStateUnknown:  "UNKNOWN",
+}
+
+// GetAggregatedStatus : Get the worst status of all entities, as calculateStatus does for events
+func GetAggregatedStatus(statusMap map[string]EntityStatus) int {
+	status := sensu.CheckStateOK
+	for _, entity := range statusMap {
+		status = calculateStatus(status, entity.Status)
+	}
+	return status
+}
+
+// WriteNagiosResult : Write the entities status as a Nagios plugin output.
+// The first line holds the aggregated status and the performance data, followed by one line per
+// entity not OK, worst first
+func WriteNagiosResult(w io.Writer, statusMap map[string]EntityStatus, info map[string]EntityInfo) error {
+	ctx := log.WithFields(log.Fields{
+		"file":     "sensu/nagios.go",
+		"function": "WriteNagiosResult",
+	})
+
+	status := GetAggregatedStatus(statusMap)
+	summary := GetStatusSummary(statusMap)
+	ctx.Debugf("Aggregated status %d for %d entities", status, summary.Total)
+
+	counts := []string{
+		fmt.Sprintf("%d critical", summary.Critical),
+		fmt.Sprintf("%d warning", summary.Warning),
+	}
+	if summary.Unknown > 0 {
+		counts = append(counts, fmt.Sprintf("%d unknown", summary.Unknown))
+	}
+
+	var perfdata []string
+	for _, field := range []statusField{
+		{"critical", summary.Critical},
+		{"warning", summary.Warning},
+		{"unknown", summary.Unknown},
+		{"ok", summary.Ok},
+	} {
+		perfdata = append(perfdata, fmt.Sprintf("%s=%d;;;0;%d", field.Name, field.Value, summary.Total))
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "ENTITIES %s - %s of %d | %s\n",
+		nagiosStateNames[status],
+		strings.Join(counts, ", "),
+		summary.Total,
+		strings.Join(perfdata, " "),
+	)
+
+	// Long output listing the offenders
+	for _, entity := range sortedBySeverity(statusMap) {
+		entityStatus := statusMap[entity]
+		if entityStatus.Status == sensu.CheckStateOK {
+			break
+		}
+		name := entity
+		if check := info[entity].WorstCheck; len(check) > 0 {
+			name += " (" + check + ")"
+		}
+		fmt.Fprintf(bw, "%s %s: %d critical, %d warning, %d unknown of %d events\n",
+			translateStatus(entityStatus.Status),
+			name,
+			entityStatus.Critical,
+			entityStatus.Warning,
+			entityStatus.Unknown,
+			entityStatus.Total,
+		)
+	}
+
+	return bw.Flush()
+}
+
+// nagiosFormatter : Nagios plugin output, exiting with the aggregated status
+type nagiosFormatter struct{}
+
+// Format : Write the entities status as a Nagios plugin output
+func (nagiosFormatter) Format(w io.Writer, data FormatData) error {
+	return WriteNagiosResult(w, data.StatusMap, data.EntitiesInfo())
+}
+
+// StatusOnly : The worst checks come with the entities details
+func (nagiosFormatter) StatusOnly() bool {
+	return true
+}
+
+// ExitStatus : Aggregated status of the entities
+func (nagiosFormatter) ExitStatus(data FormatData) int {
+	return GetAggregatedStatus(data.StatusMap)
+}
@@ -0,0 +1,53 @@
+package sensu
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/sensu/sensu-plugin-sdk/sensu"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetAggregatedStatus(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(sensu.CheckStateOK, GetAggregatedStatus(nil))
+	assert.Equal(sensu.CheckStateCritical, GetAggregatedStatus(map[string]EntityStatus{
+		"web": {Status: sensu.CheckStateWarning},
+		"db":  {Status: sensu.CheckStateCritical},
+	}))
+	assert.Equal(sensu.CheckStateWarning, GetAggregatedStatus(map[string]EntityStatus{
+		"web": {Status: sensu.CheckStateUnknown},
+		"db":  {Status: sensu.CheckStateWarning},
+	}))
+}
+
+func TestWriteNagiosResult(t *testing.T) {
+	assert := assert.New(t)
+
+	statusMap := map[string]EntityStatus{
+		"localhost":  {Status: sensu.CheckStateOK, Ok: 1, Total: 1},
+		"web|1":      {Status: sensu.CheckStateWarning, Warning: 1, Total: 1},
+		"<db1>":      {Status: sensu.CheckStateCritical, Critical: 1, Ok: 1, Total: 2},
+		"localhost2": {Status: sensu.CheckStateUnknown, Unknown: 1, Total: 1},
+	}
+	var buf bytes.Buffer
+	assert.NoError(WriteNagiosResult(&buf, statusMap, map[string]EntityInfo{"<db1>": {WorstCheck: "disk"}}))
+	assert.Equal(
+		"ENTITIES CRITICAL - 1 critical, 1 warning, 1 unknown of 4 | critical=1;;;0;4 warning=1;;;0;4 unknown=1;;;0;4 ok=1;;;0;4\n"+
+			"CRIT <db1> (disk): 1 critical, 0 warning, 0 unknown of 2 events\n"+
+			"WARN web|1: 0 critical, 1 warning, 0 unknown of 1 events\n"+
+			"UNKN localhost2: 0 critical, 0 warning, 1 unknown of 1 events\n",
+		buf.String(),
+	)
+
+	buf.Reset()
+	assert.NoError(WriteNagiosResult(&buf, map[string]EntityStatus{"localhost": {Ok: 1, Total: 1}}, nil))
+	assert.Equal("ENTITIES OK - 0 critical, 0 warning of 1 | critical=0;;;0;1 warning=0;;;0;1 unknown=0;;;0;1 ok=1;;;0;1\n", buf.String())
+
+	formatter, err := GetFormatter("nagios")
+	assert.NoError(err)
+	exit, ok := formatter.(ExitStatuser)
+	assert.True(ok)
+	assert.Equal(sensu.CheckStateCritical, exit.ExitStatus(FormatData{StatusMap: statusMap}))
+}
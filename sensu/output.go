@@ -3,52 +3,202 @@ package sensu
 import (
 	"encoding/json"
 	"fmt"
-	"os"
-	"text/tabwriter"
+	"io"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/apex/log"
-	"gopkg.in/yaml.v2"
+	v2 "github.com/sensu/core/v2"
 )
 
-// PrintTabularResult : Print in tabular format the Entities Status result
-func PrintTabularResult(statusMap map[string]EntityStatus) {
-	ctx := log.WithFields(log.Fields{
-		"file":     "sensu/entities.go",
-		"function": "GetEntityStatus",
+// FormatData : Collected data and options handed to the output formats
+type FormatData struct {
+	Events    []v2.Event
+	StatusMap map[string]EntityStatus
+	// Info holds the details of the entities, computed from Events when nil
+	Info      map[string]EntityInfo
+	Namespace string
+	Cluster   string
+	Generated time.Time
+	// Tabular holds the options of the tabular and wide formats
+	Tabular TabularOptions
+	// Labels and NoHeaders are the options of the csv and tsv formats
+	Labels    []string
+	NoHeaders bool
+	// JUnit holds the options of the junit format
+	JUnit JUnitOptions
+}
+
+// TemplateData : Build the template and jsonpath data model
+func (d FormatData) TemplateData() TemplateData {
+	return NewTemplateData(d.StatusMap, TemplateMetadata{
+		Namespace: d.Namespace,
+		Cluster:   d.Cluster,
+		Generated: d.Generated,
 	})
+}
+
+// EntitiesInfo : Details of the entities, from Info when set, from Events otherwise
+func (d FormatData) EntitiesInfo() map[string]EntityInfo {
+	if d.Info != nil {
+		return d.Info
+	}
+	return GetEntitiesInfo(d.Events)
+}
+
+// Formatter : Output format of the entities status
+type Formatter interface {
+	Format(w io.Writer, data FormatData) error
+}
+
+// ExitStatuser : Formatter whose output comes with a process exit status, as Nagios plugins do
+type ExitStatuser interface {
+	ExitStatus(data FormatData) int
+}
+
+// FormatterFunc : Adapter allowing a function to be used as a Formatter
+type FormatterFunc func(w io.Writer, data FormatData) error
+
+// Format : Call f(w, data)
+func (f FormatterFunc) Format(w io.Writer, data FormatData) error {
+	return f(w, data)
+}
+
+// StatusOnlyFormatter : Formatter only using the StatusMap and Info of the FormatData, not its Events.
+// The entities status and details can then be aggregated as the events are read, without keeping them
+type StatusOnlyFormatter interface {
+	StatusOnly() bool
+}
+
+// IsStatusOnly : Tell whether the formatter only uses the StatusMap and Info of the FormatData
+func IsStatusOnly(formatter Formatter) bool {
+	f, ok := formatter.(StatusOnlyFormatter)
+	return ok && f.StatusOnly()
+}
+
+// StatusFormatterFunc : Adapter allowing a function only using the StatusMap and Info to be used as a Formatter
+type StatusFormatterFunc func(w io.Writer, data FormatData) error
+
+// Format : Call f(w, data)
+func (f StatusFormatterFunc) Format(w io.Writer, data FormatData) error {
+	return f(w, data)
+}
+
+// StatusOnly : The function only uses the StatusMap and Info
+func (f StatusFormatterFunc) StatusOnly() bool {
+	return true
+}
+
+// formats : Registry of the output formats, by name
+var formats = map[string]Formatter{
+	"tabular": StatusFormatterFunc(func(w io.Writer, data FormatData) error {
+		return WriteTabularResult(w, data.StatusMap, tabularOptions(data, DefaultColumns))
+	}),
+	"wide": StatusFormatterFunc(func(w io.Writer, data FormatData) error {
+		return WriteTabularResult(w, data.StatusMap, tabularOptions(data, WideColumns))
+	}),
+	"json": StatusFormatterFunc(func(w io.Writer, data FormatData) error {
+		return WriteJSONResult(w, data.StatusMap)
+	}),
+	"wrapped-json": StatusFormatterFunc(func(w io.Writer, data FormatData) error {
+		return WriteWrappedJSON(w, data.StatusMap, data.Namespace)
+	}),
+	"yaml": StatusFormatterFunc(func(w io.Writer, data FormatData) error {
+		return WriteWrappedYAML(w, data.StatusMap, data.Namespace)
+	}),
+	"prometheus": FormatterFunc(func(w io.Writer, data FormatData) error {
+		return WritePrometheusMetrics(w, data.Namespace, data.Events)
+	}),
+	"influx": StatusFormatterFunc(func(w io.Writer, data FormatData) error {
+		return WriteInfluxLines(w, data.StatusMap, data.Namespace, data.Cluster, data.Generated)
+	}),
+	"graphite": StatusFormatterFunc(func(w io.Writer, data FormatData) error {
+		return WriteGraphiteLines(w, data.StatusMap, data.Namespace, data.Cluster, data.Generated)
+	}),
+	"csv": FormatterFunc(func(w io.Writer, data FormatData) error {
+		return WriteDelimitedResult(w, data.StatusMap, delimitedOptions(data, ','))
+	}),
+	"tsv": FormatterFunc(func(w io.Writer, data FormatData) error {
+		return WriteDelimitedResult(w, data.StatusMap, delimitedOptions(data, '\t'))
+	}),
+	"markdown": StatusFormatterFunc(func(w io.Writer, data FormatData) error {
+		return WriteMarkdownResult(w, data.StatusMap, data.Namespace, data.Generated)
+	}),
+	"html": StatusFormatterFunc(func(w io.Writer, data FormatData) error {
+		return WriteHTMLResult(w, data.StatusMap, data.Namespace, data.Generated)
+	}),
+	"junit": FormatterFunc(func(w io.Writer, data FormatData) error {
+		return WriteJUnitResult(w, data.Events, data.Namespace, data.Generated, data.JUnit)
+	}),
+	"nagios": nagiosFormatter{},
+}
+
+// RegisterFormat : Add an output format to the registry, replacing any format of the same name
+func RegisterFormat(name string, formatter Formatter) {
+	formats[name] = formatter
+}
+
+// GetFormatter : Look up an output format by name
+func GetFormatter(name string) (Formatter, error) {
+	formatter, ok := formats[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown format %q, authorized values: %s", name, strings.Join(FormatNames(), ", "))
+	}
+	return formatter, nil
+}
 
-	ctx.Infof("using PrintTabularResult for %d entities", len(statusMap))
-	w := tabwriter.NewWriter(os.Stdout, 1, 1, 3, ' ', tabwriter.AlignRight|tabwriter.Debug)
-	fmt.Fprintln(w, "Entity\tStatus\tEvents\tSilenced\tCrtical\tWarning\tUnkown\tOk")
-	fmt.Fprintln(w, "------\t------\t------\t--------\t-------\t-------\t------\t--")
-	for entity, status := range statusMap {
-		fmt.Fprintf(
-			w,
-			"%s\t%s\t%d\t%d\t%d\t%d\t%d\t%d\n",
-			entity,
-			translateStatus(status.Status),
-			status.Total,
-			status.Silenced,
-			status.Critical,
-			status.Warning,
-			status.Unknown,
-			status.Ok,
-		)
+// FormatNames : Names of the registered output formats, sorted
+func FormatNames() []string {
+	names := make([]string, 0, len(formats))
+	for name := range formats {
+		names = append(names, name)
 	}
-	w.Flush()
+	sort.Strings(names)
+	return names
 }
 
-// PrintJSONResult : Export data in JSON format
-func PrintJSONResult(statusMap map[string]EntityStatus) {
-	jsonString, _ := json.MarshalIndent(statusMap, "", "\t")
-	fmt.Println(string(jsonString))
+func tabularOptions(data FormatData, columns []string) TabularOptions {
+	opts := data.Tabular
+	if len(opts.Columns) == 0 {
+		opts.Columns = columns
+	}
+	if opts.Info == nil {
+		opts.Info = data.EntitiesInfo()
+	}
+	return opts
+}
+
+func delimitedOptions(data FormatData, comma rune) DelimitedOptions {
+	return DelimitedOptions{
+		Comma:        comma,
+		Namespace:    data.Namespace,
+		Labels:       data.Labels,
+		EntityLabels: GetEntitiesLabels(data.Events),
+		NoHeaders:    data.NoHeaders,
+	}
 }
 
-// PrintYAMLResult : Export data in JSON format
-func PrintYAMLResult(statusMap map[string]EntityStatus) {
-	data, err := yaml.Marshal(statusMap)
+// TabularLines : Render in tabular format the status of the given entities, in the given order.
+// The two first lines are the table header
+func TabularLines(statusMap map[string]EntityStatus, entities []string) []string {
+	// Default columns are always valid
+	lines, _ := renderTable(statusMap, entities, TabularOptions{})
+	return lines
+}
+
+// WriteJSONResult : Write the entities status in JSON format, as a map of entity status
+func WriteJSONResult(w io.Writer, statusMap map[string]EntityStatus) error {
+	ctx := log.WithFields(log.Fields{
+		"file":     "sensu/output.go",
+		"function": "WriteJSONResult",
+	})
+
+	ctx.Infof("using WriteJSONResult for %d entities", len(statusMap))
+	jsonString, err := json.MarshalIndent(statusMap, "", "\t")
 	if err != nil {
-		fmt.Println("Error: ", err.Error())
+		return err
 	}
-	fmt.Println(string(data))
+	_, err = fmt.Fprintln(w, string(jsonString))
+	return err
 }
@@ -0,0 +1,68 @@
+package sensu
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/sensu/sensu-plugin-sdk/sensu"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetFormatter(t *testing.T) {
+	assert := assert.New(t)
+
+	data := FormatData{
+		StatusMap: map[string]EntityStatus{"web": {Status: sensu.CheckStateCritical, Critical: 1, Total: 1}},
+		Namespace: "default",
+		Generated: time.Unix(1700000000, 0),
+	}
+
+	formatter, err := GetFormatter("json")
+	assert.NoError(err)
+	var buf bytes.Buffer
+	assert.NoError(formatter.Format(&buf, data))
+	assert.Equal("{\n\t\"web\": {\n\t\t\"status\": 2,\n\t\t\"silenced\": 0,\n\t\t\"critical\": 1,\n\t\t\"warning\": 0,\n\t\t\"unknown\": 0,\n\t\t\"ok\": 0,\n\t\t\"total\": 1\n\t}\n}\n", buf.String())
+
+	formatter, err = GetFormatter("graphite")
+	assert.NoError(err)
+	buf.Reset()
+	assert.NoError(formatter.Format(&buf, data))
+	assert.Contains(buf.String(), "sensu.default.web.status 2 1700000000\n")
+
+	_, err = GetFormatter("xml")
+	assert.EqualError(err, `unknown format "xml", authorized values: `+
+		"csv, graphite, html, influx, json, junit, markdown, nagios, prometheus, tabular, tsv, wide, wrapped-json, yaml")
+}
+
+func TestRegisterFormat(t *testing.T) {
+	assert := assert.New(t)
+
+	RegisterFormat("count", FormatterFunc(func(w io.Writer, data FormatData) error {
+		_, err := io.WriteString(w, "entities: 1\n")
+		return err
+	}))
+	defer delete(formats, "count")
+
+	formatter, err := GetFormatter("count")
+	assert.NoError(err)
+	var buf bytes.Buffer
+	assert.NoError(formatter.Format(&buf, FormatData{}))
+	assert.Equal("entities: 1\n", buf.String())
+	assert.Contains(FormatNames(), "count")
+	// Formats may use the events unless they tell otherwise
+	assert.False(IsStatusOnly(formatter))
+}
+
+func TestIsStatusOnly(t *testing.T) {
+	assert := assert.New(t)
+
+	for name, statusOnly := range map[string]bool{"json": true, "influx": true, "html": true, "tabular": true, "wide": true, "nagios": true, "csv": false, "prometheus": false, "junit": false} {
+		formatter, err := GetFormatter(name)
+		assert.NoError(err)
+		assert.Equal(statusOnly, IsStatusOnly(formatter), name)
+	}
+	assert.True(IsStatusOnly(TemplateFormatter{}))
+	assert.True(IsStatusOnly(JSONPathFormatter{}))
+}
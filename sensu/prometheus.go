@@ -0,0 +1,92 @@
+package sensu
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/apex/log"
+	v2 "github.com/sensu/core/v2"
+	"github.com/sensu/sensu-plugin-sdk/sensu"
+)
+
+// prometheusSeverities : Severity label values, in output order
+var prometheusSeverities = []string{"critical", "warning", "unknown", "ok"}
+
+// prometheusLabelEscaper : Escape label values as required by the exposition format
+var prometheusLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// WritePrometheusMetrics : Write the entities status in Prometheus text exposition format
+func WritePrometheusMetrics(w io.Writer, namespace string, events []v2.Event) error {
+	agg := NewAggregator()
+	for _, evt := range events {
+		agg.Add(evt)
+	}
+	return writePrometheusMetrics(w, namespace, agg.snapshot(anyNamespace), agg.eventCounts(anyNamespace))
+}
+
+// WriteAggregatorMetrics : Write the status of the entities of a namespace accounted for by an
+// aggregator in Prometheus text exposition format, see WritePrometheusMetrics
+func WriteAggregatorMetrics(w io.Writer, namespace string, agg *Aggregator) error {
+	match := func(key checkKey) bool { return key.Namespace == namespace }
+	return writePrometheusMetrics(w, namespace, agg.snapshot(match), agg.eventCounts(match))
+}
+
+// writePrometheusMetrics : Write the entities status and their events count per severity and silencing
+func writePrometheusMetrics(w io.Writer, namespace string, statusMap map[string]EntityStatus, counts map[string]map[string]map[bool]int) error {
+	ctx := log.WithFields(log.Fields{
+		"file":     "sensu/prometheus.go",
+		"function": "WritePrometheusMetrics",
+	})
+
+	entities := sortedEntities(statusMap)
+	ns := prometheusLabelEscaper.Replace(namespace)
+	ctx.Debugf("Writing metrics for %d entities", len(entities))
+
+	var b strings.Builder
+
+	b.WriteString("# HELP sensu_entities Number of entities by aggregated status\n")
+	b.WriteString("# TYPE sensu_entities gauge\n")
+	entitiesBySeverity := make(map[string]int)
+	for _, status := range statusMap {
+		entitiesBySeverity[severityName(status.Status)]++
+	}
+	for _, severity := range prometheusSeverities {
+		fmt.Fprintf(&b, "sensu_entities{namespace=\"%s\",status=\"%s\"} %d\n", ns, severity, entitiesBySeverity[severity])
+	}
+
+	b.WriteString("# HELP sensu_entity_status Aggregated status of the entity (0 OK, 1 warning, 2 critical, 3 unknown)\n")
+	b.WriteString("# TYPE sensu_entity_status gauge\n")
+	for _, entity := range entities {
+		fmt.Fprintf(&b, "sensu_entity_status{namespace=\"%s\",entity=\"%s\"} %d\n", ns, prometheusLabelEscaper.Replace(entity), statusMap[entity].Status)
+	}
+
+	b.WriteString("# HELP sensu_entity_events Number of events of the entity by severity and silencing\n")
+	b.WriteString("# TYPE sensu_entity_events gauge\n")
+	for _, entity := range entities {
+		name := prometheusLabelEscaper.Replace(entity)
+		for _, severity := range prometheusSeverities {
+			for _, silenced := range []bool{false, true} {
+				fmt.Fprintf(&b, "sensu_entity_events{namespace=\"%s\",entity=\"%s\",severity=\"%s\",silenced=\"%s\"} %d\n",
+					ns, name, severity, strconv.FormatBool(silenced), counts[entity][severity][silenced])
+			}
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func severityName(status int) string {
+	switch status {
+	case sensu.CheckStateCritical:
+		return "critical"
+	case sensu.CheckStateWarning:
+		return "warning"
+	case sensu.CheckStateOK:
+		return "ok"
+	default:
+		return "unknown"
+	}
+}
@@ -0,0 +1,37 @@
+package sensu
+
+import (
+	"bytes"
+	"testing"
+
+	corev2 "github.com/sensu/core/v2"
+	"github.com/sensu/sensu-plugin-sdk/sensu"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWritePrometheusMetrics(t *testing.T) {
+	assert := assert.New(t)
+
+	evt1 := *corev2.FixtureEvent("localhost", "dummy-check1")
+	evt2 := *corev2.FixtureEvent("localhost", "dummy-check2")
+	evt3 := *corev2.FixtureEvent(`web"1`, "dummy-check1")
+
+	evt1.Check.Status = sensu.CheckStateCritical
+	evt2.Check.Status = sensu.CheckStateWarning
+	evt2.Check.Silenced = []string{"test"}
+	evt3.Check.Status = sensu.CheckStateOK
+
+	var buf bytes.Buffer
+	err := WritePrometheusMetrics(&buf, "default", []corev2.Event{evt1, evt2, evt3})
+	assert.NoError(err)
+
+	out := buf.String()
+	assert.Contains(out, "# TYPE sensu_entity_status gauge\n")
+	assert.Contains(out, `sensu_entities{namespace="default",status="critical"} 1`+"\n")
+	assert.Contains(out, `sensu_entities{namespace="default",status="ok"} 1`+"\n")
+	assert.Contains(out, `sensu_entity_status{namespace="default",entity="localhost"} 2`+"\n")
+	assert.Contains(out, `sensu_entity_status{namespace="default",entity="web\"1"} 0`+"\n")
+	assert.Contains(out, `sensu_entity_events{namespace="default",entity="localhost",severity="critical",silenced="false"} 1`+"\n")
+	assert.Contains(out, `sensu_entity_events{namespace="default",entity="localhost",severity="warning",silenced="true"} 1`+"\n")
+	assert.Contains(out, `sensu_entity_events{namespace="default",entity="localhost",severity="warning",silenced="false"} 0`+"\n")
+}
@@ -0,0 +1,153 @@
+package sensu
+
+import (
+	"bufio"
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/apex/log"
+)
+
+// markdownEscaper : Escape characters breaking a GitHub-flavoured markdown table cell
+var markdownEscaper = strings.NewReplacer("|", `\|`, "\n", " ")
+
+// sortedBySeverity : Entities names, worst status first then by name
+func sortedBySeverity(statusMap map[string]EntityStatus) []string {
+	entities := sortedEntities(statusMap)
+	sort.SliceStable(entities, func(i, j int) bool {
+		return StatusSeverity(statusMap[entities[i]].Status) > StatusSeverity(statusMap[entities[j]].Status)
+	})
+	return entities
+}
+
+// WriteMarkdownResult : Write the entities status as a GitHub-flavoured markdown report
+func WriteMarkdownResult(w io.Writer, statusMap map[string]EntityStatus, namespace string, ts time.Time) error {
+	ctx := log.WithFields(log.Fields{
+		"file":     "sensu/report.go",
+		"function": "WriteMarkdownResult",
+	})
+
+	summary := GetStatusSummary(statusMap)
+
+	ctx.Debugf("Writing report for %d entities", len(statusMap))
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "## Entities status - %s\n\n", markdownEscaper.Replace(namespace))
+	fmt.Fprintf(bw, "Generated on %s\n\n", ts.Format(time.RFC1123))
+	fmt.Fprintf(bw, "**%d** entities: **%d** critical, **%d** warning, **%d** unknown, **%d** ok\n\n",
+		summary.Total, summary.Critical, summary.Warning, summary.Unknown, summary.Ok)
+
+	fmt.Fprintln(bw, "| Entity | Status | Events | Silenced | Critical | Warning | Unknown | Ok |")
+	fmt.Fprintln(bw, "|--------|--------|-------:|---------:|---------:|--------:|--------:|---:|")
+	for _, entity := range sortedBySeverity(statusMap) {
+		status := statusMap[entity]
+		fmt.Fprintf(bw, "| %s | `%s` | %d | %d | %d | %d | %d | %d |\n",
+			markdownEscaper.Replace(entity),
+			translateStatus(status.Status),
+			status.Total,
+			status.Silenced,
+			status.Critical,
+			status.Warning,
+			status.Unknown,
+			status.Ok,
+		)
+	}
+
+	return bw.Flush()
+}
+
+var htmlReportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"statusName": StatusName,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+{{if gt .Refresh 0}}<meta http-equiv="refresh" content="{{.Refresh}}">
+{{end}}<title>Entities status - {{.Namespace}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.8em; text-align: right; }
+th:first-child, td:first-child { text-align: left; }
+.summary span { margin-right: 1.5em; font-weight: bold; }
+.s0 { background: #dff0d8; }
+.s1 { background: #fcf8e3; }
+.s2 { background: #f2dede; }
+.s3 { background: #e8e8e8; }
+.error { color: #a94442; }
+</style>
+</head>
+<body>
+<h1>Entities status - {{.Namespace}}</h1>
+<p>{{if .Generated.IsZero}}Not collected yet{{else}}Generated on {{.Generated.Format "Mon, 02 Jan 2006 15:04:05 MST"}}{{end}}</p>
+{{with .Err}}<p class="error">Last collection failed: {{.}}</p>
+{{end}}<p class="summary"><span>{{.Summary.Total}} entities</span><span class="s2">{{.Summary.Critical}} critical</span><span class="s1">{{.Summary.Warning}} warning</span><span class="s3">{{.Summary.Unknown}} unknown</span><span class="s0">{{.Summary.Ok}} ok</span></p>
+{{if .Groups}}<h2>Groups</h2>
+<table>
+<tr><th>Group</th><th>Status</th><th>Entities</th><th>Critical</th><th>Warning</th><th>Unknown</th><th>Ok</th></tr>
+{{range .Groups}}<tr class="s{{.Status}}"><td>{{.Name}}</td><td>{{statusName .Status}}</td><td>{{.Total}}</td><td>{{.Critical}}</td><td>{{.Warning}}</td><td>{{.Unknown}}</td><td>{{.Ok}}</td></tr>
+{{end}}</table>
+<h2>Entities</h2>
+{{end}}<table>
+<tr><th>Entity</th><th>Status</th><th>Events</th><th>Silenced</th><th>Critical</th><th>Warning</th><th>Unknown</th><th>Ok</th></tr>
+{{range .Entities}}<tr class="s{{.Status}}"><td>{{if $.EntityLink}}<a href="{{$.EntityLink}}{{.Name}}">{{.Name}}</a>{{else}}{{.Name}}{{end}}</td><td>{{statusName .Status}}</td><td>{{.Total}}</td><td>{{.Silenced}}</td><td>{{.Critical}}</td><td>{{.Warning}}</td><td>{{.Unknown}}</td><td>{{.Ok}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// HTMLEntityRow : Entity of the HTML report
+type HTMLEntityRow struct {
+	Name string
+	EntityStatus
+}
+
+// HTMLGroupRow : Group of the HTML report
+type HTMLGroupRow struct {
+	Name string
+	GroupStatus
+}
+
+// HTMLPage : Data of the HTML report. The status page of the serve subcommand is the same report,
+// with the groups, a refresh, the error of the last collection and links to the entities
+type HTMLPage struct {
+	Namespace string
+	// Generated is the collection time, the page tells nothing was collected yet when zero
+	Generated time.Time
+	Summary   StatusSummary
+	Entities  []HTMLEntityRow
+	// Groups are listed before the entities when set
+	Groups []HTMLGroupRow
+	// Refresh reloads the page every Refresh seconds when positive
+	Refresh int
+	// Err is the error of the last collection
+	Err error
+	// EntityLink prefixes the name of an entity to link it, the entities are not linked when empty
+	EntityLink string
+}
+
+// NewHTMLPage : HTML report of the entities status, worst entities first
+func NewHTMLPage(statusMap map[string]EntityStatus, namespace string, ts time.Time) HTMLPage {
+	page := HTMLPage{
+		Namespace: namespace,
+		Generated: ts,
+		Summary:   GetStatusSummary(statusMap),
+	}
+	for _, entity := range sortedBySeverity(statusMap) {
+		page.Entities = append(page.Entities, HTMLEntityRow{Name: entity, EntityStatus: statusMap[entity]})
+	}
+	return page
+}
+
+// WriteHTMLPage : Write the HTML report
+func WriteHTMLPage(w io.Writer, page HTMLPage) error {
+	return htmlReportTemplate.Execute(w, page)
+}
+
+// WriteHTMLResult : Write the entities status as a self-contained HTML page
+func WriteHTMLResult(w io.Writer, statusMap map[string]EntityStatus, namespace string, ts time.Time) error {
+	return WriteHTMLPage(w, NewHTMLPage(statusMap, namespace, ts))
+}
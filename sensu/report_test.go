@@ -0,0 +1,62 @@
+package sensu
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sensu/sensu-plugin-sdk/sensu"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteMarkdownResult(t *testing.T) {
+	assert := assert.New(t)
+
+	statusMap := map[string]EntityStatus{
+		"localhost":  {Status: sensu.CheckStateOK, Ok: 1, Total: 1},
+		"web|1":      {Status: sensu.CheckStateWarning, Warning: 1, Total: 1},
+		"<db1>":      {Status: sensu.CheckStateCritical, Critical: 1, Ok: 1, Total: 2},
+		"localhost2": {Status: sensu.CheckStateUnknown, Unknown: 1, Total: 1},
+	}
+	var buf bytes.Buffer
+	assert.NoError(WriteMarkdownResult(&buf, statusMap, "default", time.Unix(1700000000, 0).UTC()))
+
+	out := buf.String()
+	assert.Contains(out, "## Entities status - default\n")
+	assert.Contains(out, "Generated on Tue, 14 Nov 2023 22:13:20 UTC\n")
+	assert.Contains(out, "**4** entities: **1** critical, **1** warning, **1** unknown, **1** ok\n")
+
+	// Worst entities first, pipes escaped
+	rows := strings.Split(strings.TrimSpace(out[strings.Index(out, "|--"):]), "\n")[1:]
+	assert.Equal([]string{
+		"| <db1> | `CRIT` | 2 | 0 | 1 | 0 | 0 | 1 |",
+		"| web\\|1 | `WARN` | 1 | 0 | 0 | 1 | 0 | 0 |",
+		"| localhost2 | `UNKN` | 1 | 0 | 0 | 0 | 1 | 0 |",
+		"| localhost | `OK` | 1 | 0 | 0 | 0 | 0 | 1 |",
+	}, rows)
+}
+
+func TestWriteHTMLResult(t *testing.T) {
+	assert := assert.New(t)
+
+	statusMap := map[string]EntityStatus{
+		"localhost":  {Status: sensu.CheckStateOK, Ok: 1, Total: 1},
+		"web|1":      {Status: sensu.CheckStateWarning, Warning: 1, Total: 1},
+		"<db1>":      {Status: sensu.CheckStateCritical, Critical: 1, Ok: 1, Total: 2},
+		"localhost2": {Status: sensu.CheckStateUnknown, Unknown: 1, Total: 1},
+	}
+	var buf bytes.Buffer
+	assert.NoError(WriteHTMLResult(&buf, statusMap, "default", time.Unix(1700000000, 0).UTC()))
+
+	out := buf.String()
+	assert.Contains(out, "<title>Entities status - default</title>")
+	assert.Contains(out, "Generated on Tue, 14 Nov 2023 22:13:20 UTC")
+	assert.Contains(out, `<span class="s2">1 critical</span>`)
+	assert.Contains(out, `<tr class="s2"><td>&lt;db1&gt;</td><td>CRIT</td>`)
+	assert.Less(strings.Index(out, "&lt;db1&gt;"), strings.Index(out, "<td>localhost</td>"))
+	// The additions of the serve status page are left out
+	assert.NotContains(out, "http-equiv")
+	assert.NotContains(out, "<h2>Groups</h2>")
+	assert.NotContains(out, "<a href")
+}
@@ -0,0 +1,239 @@
+package sensu
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Snapshot : Entities status saved at a point in time
+type Snapshot struct {
+	Metadata TemplateMetadata        `json:"metadata" yaml:"metadata"`
+	Entities map[string]EntityStatus `json:"entities" yaml:"entities"`
+}
+
+// WriteSnapshot : Write a snapshot in JSON format
+func WriteSnapshot(w io.Writer, snapshot Snapshot) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(snapshot)
+}
+
+// SaveSnapshot : Atomically write a snapshot file
+func SaveSnapshot(path string, snapshot Snapshot) error {
+	return WriteFileAtomic(path, func(w io.Writer) error {
+		return WriteSnapshot(w, snapshot)
+	})
+}
+
+// LoadSnapshot : Read a snapshot file
+func LoadSnapshot(path string) (Snapshot, error) {
+	var snapshot Snapshot
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return snapshot, err
+	}
+	if err := json.Unmarshal(raw, &snapshot); err != nil {
+		return snapshot, fmt.Errorf("invalid snapshot %s: %w", path, err)
+	}
+	return snapshot, nil
+}
+
+// Kinds of change between two snapshots, in output order
+const (
+	ChangeAppeared    = "appeared"
+	ChangeDisappeared = "disappeared"
+	ChangeWorse       = "worse"
+	ChangeRecovered   = "recovered"
+)
+
+var changeOrder = map[string]int{
+	ChangeAppeared:    0,
+	ChangeDisappeared: 1,
+	ChangeWorse:       2,
+	ChangeRecovered:   3,
+}
+
+// EntityChange : Change of an entity between two snapshots.
+// Before is nil for appeared entities, After is nil for disappeared entities
+type EntityChange struct {
+	Name   string        `json:"name" yaml:"name"`
+	Change string        `json:"change" yaml:"change"`
+	Before *EntityStatus `json:"before,omitempty" yaml:"before,omitempty"`
+	After  *EntityStatus `json:"after,omitempty" yaml:"after,omitempty"`
+}
+
+// SnapshotDiff : Entities that appeared, disappeared, got worse or recovered between two snapshots
+type SnapshotDiff struct {
+	From    TemplateMetadata `json:"from" yaml:"from"`
+	To      TemplateMetadata `json:"to" yaml:"to"`
+	Changes []EntityChange   `json:"changes" yaml:"changes"`
+}
+
+// DiffSnapshots : Compare two snapshots. Entities whose status severity is unchanged are left out
+func DiffSnapshots(from Snapshot, to Snapshot) SnapshotDiff {
+	diff := SnapshotDiff{From: from.Metadata, To: to.Metadata, Changes: []EntityChange{}}
+
+	for entity, after := range to.Entities {
+		before, ok := from.Entities[entity]
+		change := EntityChange{Name: entity, After: &after}
+		if !ok {
+			change.Change = ChangeAppeared
+		} else if StatusSeverity(after.Status) > StatusSeverity(before.Status) {
+			change.Change = ChangeWorse
+			change.Before = &before
+		} else if StatusSeverity(after.Status) < StatusSeverity(before.Status) {
+			change.Change = ChangeRecovered
+			change.Before = &before
+		} else {
+			continue
+		}
+		diff.Changes = append(diff.Changes, change)
+	}
+
+	for entity, before := range from.Entities {
+		if _, ok := to.Entities[entity]; !ok {
+			diff.Changes = append(diff.Changes, EntityChange{Name: entity, Change: ChangeDisappeared, Before: &before})
+		}
+	}
+
+	sort.Slice(diff.Changes, func(i, j int) bool {
+		a, b := diff.Changes[i], diff.Changes[j]
+		if a.Change != b.Change {
+			return changeOrder[a.Change] < changeOrder[b.Change]
+		}
+		return a.Name < b.Name
+	})
+
+	return diff
+}
+
+// changeStatus : Status name of one side of a change, "-" when the entity is missing
+func changeStatus(status *EntityStatus) string {
+	if status == nil {
+		return "-"
+	}
+	return translateStatus(status.Status)
+}
+
+// DiffFormatter : Output format of the difference between two snapshots
+type DiffFormatter interface {
+	FormatDiff(w io.Writer, diff SnapshotDiff) error
+}
+
+// DiffFormatterFunc : Adapter allowing a function to be used as a DiffFormatter
+type DiffFormatterFunc func(w io.Writer, diff SnapshotDiff) error
+
+// FormatDiff : Call f(w, diff)
+func (f DiffFormatterFunc) FormatDiff(w io.Writer, diff SnapshotDiff) error {
+	return f(w, diff)
+}
+
+// diffFormats : Registry of the snapshot difference output formats, by name
+var diffFormats = map[string]DiffFormatter{
+	"tabular":      DiffFormatterFunc(WriteDiffTable),
+	"wide":         DiffFormatterFunc(WriteDiffTable),
+	"json":         DiffFormatterFunc(WriteDiffJSON),
+	"yaml":         DiffFormatterFunc(WriteDiffYAML),
+	"markdown":     DiffFormatterFunc(WriteDiffMarkdown),
+	"csv":          DelimitedFormatter{Comma: ','},
+	"tsv":          DelimitedFormatter{Comma: '\t'},
+	"wrapped-json": DiffFormatterFunc(WriteDiffWrappedJSON),
+	"html":         DiffFormatterFunc(WriteDiffHTML),
+	"junit":        DiffFormatterFunc(WriteDiffJUnit),
+	"nagios":       nagiosDiffFormatter{},
+	"prometheus":   DiffFormatterFunc(WriteDiffPrometheus),
+	"influx":       DiffFormatterFunc(WriteDiffInflux),
+	"graphite":     DiffFormatterFunc(WriteDiffGraphite),
+}
+
+// GetDiffFormatter : Look up a snapshot difference output format by name
+func GetDiffFormatter(name string) (DiffFormatter, error) {
+	formatter, ok := diffFormats[name]
+	if !ok {
+		names := make([]string, 0, len(diffFormats))
+		for name := range diffFormats {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("format %q is not supported by diff, authorized values: %s", name, strings.Join(names, ", "))
+	}
+	return formatter, nil
+}
+
+// WriteDiffTable : Write the difference between two snapshots as a table
+func WriteDiffTable(w io.Writer, diff SnapshotDiff) error {
+	tw := tabwriter.NewWriter(w, 1, 1, 3, ' ', tabwriter.AlignRight|tabwriter.Debug)
+	fmt.Fprintln(tw, "Change\tEntity\tBefore\tAfter")
+	fmt.Fprintln(tw, "------\t------\t------\t-----")
+	for _, change := range diff.Changes {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", change.Change, change.Name, changeStatus(change.Before), changeStatus(change.After))
+	}
+	return tw.Flush()
+}
+
+// WriteDiffJSON : Write the difference between two snapshots in JSON format
+func WriteDiffJSON(w io.Writer, diff SnapshotDiff) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(diff)
+}
+
+// WriteDiffYAML : Write the difference between two snapshots in YAML format
+func WriteDiffYAML(w io.Writer, diff SnapshotDiff) error {
+	raw, err := yaml.Marshal(diff)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(raw)
+	return err
+}
+
+// WriteDiffDelimited : Write the difference between two snapshots as delimiter separated values,
+// without header row when noHeaders is set. Comma separated values follow RFC 4180
+func WriteDiffDelimited(w io.Writer, diff SnapshotDiff, comma rune, noHeaders bool) error {
+	cw := csv.NewWriter(w)
+	cw.Comma = comma
+	cw.UseCRLF = comma == ','
+
+	if !noHeaders {
+		if err := cw.Write([]string{"change", "entity", "before", "after"}); err != nil {
+			return err
+		}
+	}
+	for _, change := range diff.Changes {
+		if err := cw.Write([]string{change.Change, change.Name, changeStatus(change.Before), changeStatus(change.After)}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteDiffMarkdown : Write the difference between two snapshots as a markdown report
+func WriteDiffMarkdown(w io.Writer, diff SnapshotDiff) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "## Entities status changes - %s\n\n", markdownEscaper.Replace(diff.To.Namespace))
+	fmt.Fprintf(bw, "From %s to %s\n\n", diff.From.Generated.Format(time.RFC1123), diff.To.Generated.Format(time.RFC1123))
+	if len(diff.Changes) == 0 {
+		fmt.Fprintln(bw, "No change")
+		return bw.Flush()
+	}
+
+	fmt.Fprintln(bw, "| Change | Entity | Before | After |")
+	fmt.Fprintln(bw, "|--------|--------|--------|-------|")
+	for _, change := range diff.Changes {
+		fmt.Fprintf(bw, "| %s | %s | `%s` | `%s` |\n", change.Change, markdownEscaper.Replace(change.Name), changeStatus(change.Before), changeStatus(change.After))
+	}
+	return bw.Flush()
+}
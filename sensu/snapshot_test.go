@@ -0,0 +1,175 @@
+package sensu
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sensu/sensu-plugin-sdk/sensu"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSaveSnapshot(t *testing.T) {
+	assert := assert.New(t)
+
+	from := Snapshot{
+		Metadata: TemplateMetadata{Namespace: "default", Generated: time.Unix(1700000000, 0).UTC()},
+		Entities: map[string]EntityStatus{
+			"web": {Status: sensu.CheckStateOK, Ok: 1, Total: 1},
+			"db":  {Status: sensu.CheckStateCritical, Critical: 1, Total: 1},
+		},
+	}
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+	assert.NoError(SaveSnapshot(path, from))
+
+	loaded, err := LoadSnapshot(path)
+	assert.NoError(err)
+	assert.Equal(from, loaded)
+
+	_, err = LoadSnapshot(filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(err)
+}
+
+func TestDiffSnapshots(t *testing.T) {
+	assert := assert.New(t)
+
+	from := Snapshot{
+		Metadata: TemplateMetadata{Namespace: "default", Generated: time.Unix(1700000000, 0).UTC()},
+		Entities: map[string]EntityStatus{
+			"web":    {Status: sensu.CheckStateOK, Ok: 1, Total: 1},
+			"db":     {Status: sensu.CheckStateCritical, Critical: 1, Total: 1},
+			"cache":  {Status: sensu.CheckStateWarning, Warning: 1, Total: 1},
+			"legacy": {Status: sensu.CheckStateOK, Ok: 1, Total: 1},
+		},
+	}
+	to := Snapshot{
+		Metadata: TemplateMetadata{Namespace: "default", Generated: time.Unix(1700003600, 0).UTC()},
+		Entities: map[string]EntityStatus{
+			"web":   {Status: sensu.CheckStateCritical, Critical: 1, Total: 1},
+			"db":    {Status: sensu.CheckStateOK, Ok: 1, Total: 1},
+			"cache": {Status: sensu.CheckStateWarning, Warning: 2, Total: 2},
+			"queue": {Status: sensu.CheckStateOK, Ok: 1, Total: 1},
+		},
+	}
+	diff := DiffSnapshots(from, to)
+
+	var changes []string
+	for _, change := range diff.Changes {
+		changes = append(changes, change.Change+" "+change.Name)
+	}
+	// Cache counters changed but not its status
+	assert.Equal([]string{"appeared queue", "disappeared legacy", "worse web", "recovered db"}, changes)
+	assert.Nil(diff.Changes[0].Before)
+	assert.Nil(diff.Changes[1].After)
+	assert.Equal(sensu.CheckStateCritical, diff.Changes[3].Before.Status)
+
+	assert.Empty(DiffSnapshots(from, from).Changes)
+}
+
+func TestWriteDiffResult(t *testing.T) {
+	assert := assert.New(t)
+
+	from := Snapshot{
+		Metadata: TemplateMetadata{Namespace: "default", Generated: time.Unix(1700000000, 0).UTC()},
+		Entities: map[string]EntityStatus{
+			"web":    {Status: sensu.CheckStateOK, Ok: 1, Total: 1},
+			"db":     {Status: sensu.CheckStateCritical, Critical: 1, Total: 1},
+			"cache":  {Status: sensu.CheckStateWarning, Warning: 1, Total: 1},
+			"legacy": {Status: sensu.CheckStateOK, Ok: 1, Total: 1},
+		},
+	}
+	to := Snapshot{
+		Metadata: TemplateMetadata{Namespace: "default", Cluster: "eu", Generated: time.Unix(1700003600, 0).UTC()},
+		Entities: map[string]EntityStatus{
+			"web":   {Status: sensu.CheckStateCritical, Critical: 1, Total: 1},
+			"db":    {Status: sensu.CheckStateOK, Ok: 1, Total: 1},
+			"cache": {Status: sensu.CheckStateWarning, Warning: 2, Total: 2},
+			"queue": {Status: sensu.CheckStateOK, Ok: 1, Total: 1},
+		},
+	}
+	diff := DiffSnapshots(from, to)
+
+	var buf bytes.Buffer
+	assert.NoError(WriteDiffTable(&buf, diff))
+	assert.Equal(
+		"        Change|   Entity|   Before|After\n"+
+			"        ------|   ------|   ------|-----\n"+
+			"      appeared|    queue|        -|OK\n"+
+			"   disappeared|   legacy|       OK|-\n"+
+			"         worse|      web|       OK|CRIT\n"+
+			"     recovered|       db|     CRIT|OK\n",
+		buf.String(),
+	)
+
+	formatter, err := GetDiffFormatter("csv")
+	assert.NoError(err)
+	buf.Reset()
+	assert.NoError(formatter.FormatDiff(&buf, diff))
+	assert.Equal("change,entity,before,after\r\nappeared,queue,-,OK\r\ndisappeared,legacy,OK,-\r\nworse,web,OK,CRIT\r\nrecovered,db,CRIT,OK\r\n", buf.String())
+
+	buf.Reset()
+	assert.NoError(DelimitedFormatter{Comma: '\t', NoHeaders: true}.FormatDiff(&buf, diff))
+	assert.Equal("appeared\tqueue\t-\tOK\ndisappeared\tlegacy\tOK\t-\nworse\tweb\tOK\tCRIT\nrecovered\tdb\tCRIT\tOK\n", buf.String())
+
+	buf.Reset()
+	assert.NoError(WriteDiffMarkdown(&buf, diff))
+	assert.Contains(buf.String(), "From Tue, 14 Nov 2023 22:13:20 UTC to Tue, 14 Nov 2023 23:13:20 UTC\n")
+	assert.Contains(buf.String(), "| worse | web | `OK` | `CRIT` |\n")
+
+	path, err := ParseJSONPath(`{range .changes[?(@.change=="worse")]}{.name}{"\n"}{end}`)
+	assert.NoError(err)
+	buf.Reset()
+	assert.NoError(JSONPathFormatter{Path: path}.FormatDiff(&buf, diff))
+	assert.Equal("web\n", buf.String())
+
+	_, err = GetDiffFormatter("unknown")
+	assert.Error(err)
+
+	// Every output format renders snapshot differences too
+	for _, name := range FormatNames() {
+		_, err := GetDiffFormatter(name)
+		assert.NoError(err, name)
+	}
+
+	buf.Reset()
+	assert.NoError(WriteDiffWrappedJSON(&buf, diff))
+	assert.Contains(buf.String(), `"type": "EntityStatusChange"`)
+	assert.Equal(4, strings.Count(buf.String(), `"api_version"`))
+
+	buf.Reset()
+	assert.NoError(WriteDiffHTML(&buf, diff))
+	assert.Contains(buf.String(), `<tr class="worse"><td>worse</td><td>web</td><td>OK</td><td>CRIT</td></tr>`)
+
+	buf.Reset()
+	assert.NoError(WriteDiffJUnit(&buf, diff))
+	assert.Contains(buf.String(), `<testsuites name="entities-status diff default" tests="4" failures="1" skipped="0">`)
+	assert.Contains(buf.String(), `<failure message="web: OK -&gt; CRIT" type="CRIT">`)
+
+	buf.Reset()
+	assert.NoError(WriteDiffNagios(&buf, diff))
+	assert.Equal(
+		"ENTITIES CHANGES CRITICAL - 1 appeared, 1 disappeared, 1 worse, 1 recovered | appeared=1;;;0 disappeared=1;;;0 worse=1;;;0 recovered=1;;;0\n"+
+			"appeared queue: - -> OK\n"+
+			"disappeared legacy: OK -> -\n"+
+			"worse web: OK -> CRIT\n"+
+			"recovered db: CRIT -> OK\n",
+		buf.String(),
+	)
+	assert.Equal(sensu.CheckStateCritical, nagiosDiffFormatter{}.DiffExitStatus(diff))
+	assert.Equal(sensu.CheckStateOK, GetDiffStatus(DiffSnapshots(from, from)))
+
+	buf.Reset()
+	assert.NoError(WriteDiffPrometheus(&buf, diff))
+	assert.Contains(buf.String(), "sensu_entities_changes{namespace=\"default\",change=\"worse\"} 1\n")
+	assert.Contains(buf.String(), "sensu_entity_change{namespace=\"default\",entity=\"web\",change=\"worse\",before=\"OK\",after=\"CRIT\"} 1\n")
+
+	buf.Reset()
+	assert.NoError(WriteDiffInflux(&buf, diff))
+	assert.Equal("sensu_entities_changes,namespace=default,cluster=eu appeared=1i,disappeared=1i,worse=1i,recovered=1i 1700003600000000000\n", buf.String())
+
+	buf.Reset()
+	assert.NoError(WriteDiffGraphite(&buf, diff))
+	assert.Contains(buf.String(), "sensu.eu.default.changes.worse 1 1700003600\n")
+}
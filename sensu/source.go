@@ -0,0 +1,315 @@
+package sensu
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	v2 "github.com/sensu/core/v2"
+)
+
+// EventSource : Where the events, entities, silences and namespaces are read from.
+// An empty namespace lists the objects of every namespace where the source allows it.
+// Requests to a backend are cancelled with ctx. StreamEvents hands the events to fn one at a time,
+// so they do not have to be kept in memory, and stops at the first error of fn
+type EventSource interface {
+	ListEvents(ctx context.Context, namespace string) ([]v2.Event, error)
+	StreamEvents(ctx context.Context, namespace string, fn func(evt v2.Event) error) error
+	ListEntities(ctx context.Context, namespace string) ([]v2.Entity, error)
+	ListSilences(ctx context.Context, namespace string) ([]v2.Silenced, error)
+	ListNamespaces(ctx context.Context) ([]string, error)
+}
+
+// EntityEventSource : Source able to list the events of a single entity without listing every event
+type EntityEventSource interface {
+	ListEntityEvents(ctx context.Context, namespace string, entity string) ([]v2.Event, error)
+}
+
+// RESTSource : Objects read from the REST API of the backend.
+// Lean events only hold the fields the entities status needs, see StreamLeanEvents
+type RESTSource struct {
+	URL    string
+	Header map[string]string
+	Page   PageOptions
+	Lean   bool
+}
+
+// namespaceURL : URL of a namespaced list endpoint, of every namespace when the namespace is empty
+func (s RESTSource) namespaceURL(namespace string, resource string) string {
+	if len(namespace) == 0 {
+		return fmt.Sprintf("%s/api/core/v2/%s", strings.TrimSuffix(s.URL, "/"), resource)
+	}
+	return fmt.Sprintf("%s/api/core/v2/namespaces/%s/%s", strings.TrimSuffix(s.URL, "/"), url.PathEscape(namespace), resource)
+}
+
+// ListEvents : List the events of a namespace
+func (s RESTSource) ListEvents(ctx context.Context, namespace string) ([]v2.Event, error) {
+	if s.Lean {
+		return EventExtractLean(ctx, s.URL, namespace, s.Header, s.Page)
+	}
+	return EventExtractJSONWithHeader(ctx, s.namespaceURL(namespace, "events"), s.Header, nil, s.Page)
+}
+
+// StreamEvents : Hand the events of a namespace to fn as they are decoded
+func (s RESTSource) StreamEvents(ctx context.Context, namespace string, fn func(evt v2.Event) error) error {
+	if s.Lean {
+		return StreamLeanEvents(ctx, s.URL, namespace, s.Header, s.Page, fn)
+	}
+	return StreamEvents(ctx, s.namespaceURL(namespace, "events"), s.Header, nil, s.Page, fn)
+}
+
+// ListEntityEvents : List the events of an entity
+func (s RESTSource) ListEntityEvents(ctx context.Context, namespace string, entity string) ([]v2.Event, error) {
+	return EventExtractJSONWithHeader(ctx, s.namespaceURL(namespace, "events/"+url.PathEscape(entity)), s.Header, nil, s.Page)
+}
+
+// ListEntities : List the entities of a namespace
+func (s RESTSource) ListEntities(ctx context.Context, namespace string) ([]v2.Entity, error) {
+	return listAll[v2.Entity](ctx, s.namespaceURL(namespace, "entities"), s.Header, s.Page)
+}
+
+// ListSilences : List the silences of a namespace
+func (s RESTSource) ListSilences(ctx context.Context, namespace string) ([]v2.Silenced, error) {
+	return listAll[v2.Silenced](ctx, s.namespaceURL(namespace, "silenced"), s.Header, s.Page)
+}
+
+// ListNamespaces : List the namespaces
+func (s RESTSource) ListNamespaces(ctx context.Context) ([]string, error) {
+	namespaces, err := listAll[v2.Namespace](ctx, strings.TrimSuffix(s.URL, "/")+"/api/core/v2/namespaces", s.Header, s.Page)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(namespaces))
+	for _, namespace := range namespaces {
+		names = append(names, namespace.Name)
+	}
+	return names, nil
+}
+
+// listAll : Collect every object of a paginated list endpoint
+func listAll[T any](ctx context.Context, rawURL string, header map[string]string, page PageOptions) ([]T, error) {
+	objects := []T{}
+	err := streamList(ctx, rawURL, header, nil, page, func(obj T) error {
+		objects = append(objects, obj)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return objects, nil
+}
+
+// GraphQLSource : REST source whose events are read from the GraphQL API, see StreamGraphQLEvents
+type GraphQLSource struct {
+	RESTSource
+}
+
+// ListEvents : List the events of a namespace
+func (s GraphQLSource) ListEvents(ctx context.Context, namespace string) ([]v2.Event, error) {
+	return EventExtractGraphQL(ctx, s.URL, namespace, s.Header, s.Page)
+}
+
+// StreamEvents : Hand the events of a namespace to fn as they are decoded
+func (s GraphQLSource) StreamEvents(ctx context.Context, namespace string, fn func(evt v2.Event) error) error {
+	return StreamGraphQLEvents(ctx, s.URL, namespace, s.Header, s.Page, fn)
+}
+
+// ListEntityEvents : List the events of an entity, from the REST API
+func (s GraphQLSource) ListEntityEvents(ctx context.Context, namespace string, entity string) ([]v2.Event, error) {
+	return s.RESTSource.ListEntityEvents(ctx, namespace, entity)
+}
+
+// FileSource : Objects read offline from a file, see ReadResources. The file is read again at every
+// call, so it can be updated between refreshes. Path "-" is Stdin, read once
+type FileSource struct {
+	Path  string
+	Stdin io.Reader
+
+	mu    sync.Mutex
+	stdin *Resources
+}
+
+func (s *FileSource) load() (Resources, error) {
+	if s.Path != "-" {
+		return LoadResources(s.Path)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stdin == nil {
+		stdin := s.Stdin
+		if stdin == nil {
+			stdin = os.Stdin
+		}
+		resources, err := ReadResources(stdin)
+		if err != nil {
+			return resources, fmt.Errorf("stdin: %w", err)
+		}
+		s.stdin = &resources
+	}
+	return *s.stdin, nil
+}
+
+// ListEvents : List the events of a namespace
+func (s *FileSource) ListEvents(ctx context.Context, namespace string) ([]v2.Event, error) {
+	resources, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	return FilterNamespace(resources.Events, namespace), nil
+}
+
+// StreamEvents : Hand the events of a namespace to fn. The file is read entirely first
+func (s *FileSource) StreamEvents(ctx context.Context, namespace string, fn func(evt v2.Event) error) error {
+	events, err := s.ListEvents(ctx, namespace)
+	if err != nil {
+		return err
+	}
+	return streamSlice(events, fn)
+}
+
+// ListEntities : List the entities of a namespace, the entity resources and the entities of the events
+func (s *FileSource) ListEntities(ctx context.Context, namespace string) ([]v2.Entity, error) {
+	resources, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	return resourcesEntities(resources, namespace), nil
+}
+
+// ListSilences : List the silences of a namespace
+func (s *FileSource) ListSilences(ctx context.Context, namespace string) ([]v2.Silenced, error) {
+	resources, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	return resourcesSilences(resources, namespace), nil
+}
+
+// ListNamespaces : List the namespaces of the events, entities and silences
+func (s *FileSource) ListNamespaces(ctx context.Context) ([]string, error) {
+	resources, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	return resourcesNamespaces(resources), nil
+}
+
+// FakeSource : Objects held in memory, to exercise the code reading a source without a backend.
+// Err, when set, is returned by every call
+type FakeSource struct {
+	Resources
+	Err error
+}
+
+// ListEvents : List the events of a namespace
+func (s *FakeSource) ListEvents(ctx context.Context, namespace string) ([]v2.Event, error) {
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	return FilterNamespace(s.Events, namespace), nil
+}
+
+// StreamEvents : Hand the events of a namespace to fn
+func (s *FakeSource) StreamEvents(ctx context.Context, namespace string, fn func(evt v2.Event) error) error {
+	events, err := s.ListEvents(ctx, namespace)
+	if err != nil {
+		return err
+	}
+	return streamSlice(events, fn)
+}
+
+// ListEntities : List the entities of a namespace, the entities and the entities of the events
+func (s *FakeSource) ListEntities(ctx context.Context, namespace string) ([]v2.Entity, error) {
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	return resourcesEntities(s.Resources, namespace), nil
+}
+
+// ListSilences : List the silences of a namespace
+func (s *FakeSource) ListSilences(ctx context.Context, namespace string) ([]v2.Silenced, error) {
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	return resourcesSilences(s.Resources, namespace), nil
+}
+
+// ListNamespaces : List the namespaces of the events, entities and silences
+func (s *FakeSource) ListNamespaces(ctx context.Context) ([]string, error) {
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	return resourcesNamespaces(s.Resources), nil
+}
+
+// streamSlice : Hand the events to fn one at a time, stopping at the first error
+func streamSlice(events []v2.Event, fn func(evt v2.Event) error) error {
+	for _, evt := range events {
+		if err := fn(evt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resourcesEntities : Entities of a namespace, from the entity resources then from the events
+func resourcesEntities(resources Resources, namespace string) []v2.Entity {
+	entities := []v2.Entity{}
+	seen := make(map[string]bool)
+	add := func(entity v2.Entity) {
+		if (len(namespace) > 0 && entity.Namespace != namespace) || seen[entity.Namespace+"/"+entity.Name] {
+			return
+		}
+		seen[entity.Namespace+"/"+entity.Name] = true
+		entities = append(entities, entity)
+	}
+
+	for _, entity := range resources.Entities {
+		add(entity)
+	}
+	for _, evt := range resources.Events {
+		if evt.Entity != nil {
+			add(*evt.Entity)
+		}
+	}
+	return entities
+}
+
+// resourcesSilences : Silences of a namespace
+func resourcesSilences(resources Resources, namespace string) []v2.Silenced {
+	silences := []v2.Silenced{}
+	for _, silence := range resources.Silences {
+		if len(namespace) == 0 || silence.Namespace == namespace {
+			silences = append(silences, silence)
+		}
+	}
+	return silences
+}
+
+// resourcesNamespaces : Namespaces of the events, entities and silences, sorted
+func resourcesNamespaces(resources Resources) []string {
+	set := make(map[string]bool)
+	for _, entity := range resourcesEntities(resources, "") {
+		set[entity.Namespace] = true
+	}
+	for _, evt := range resources.Events {
+		set[eventNamespace(evt)] = true
+	}
+	for _, silence := range resources.Silences {
+		set[silence.Namespace] = true
+	}
+	delete(set, "")
+
+	namespaces := make([]string, 0, len(set))
+	for namespace := range set {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+	return namespaces
+}
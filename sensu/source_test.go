@@ -0,0 +1,219 @@
+package sensu
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	corev2 "github.com/sensu/core/v2"
+	"github.com/sensu/sensu-plugin-sdk/sensu"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRESTSource(t *testing.T) {
+	assert := assert.New(t)
+
+	events := newEventsServer(t, 30, 10)
+	defer events.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.EscapedPath() {
+		case "/api/core/v2/namespaces":
+			fmt.Fprint(w, `[{"name": "default"}, {"name": "production"}]`)
+		case "/api/core/v2/namespaces/default/entities":
+			fmt.Fprint(w, `[{"metadata": {"name": "web1", "namespace": "default"}, "entity_class": "agent"}]`)
+		case "/api/core/v2/namespaces/default/silenced":
+			fmt.Fprint(w, `[{"metadata": {"name": "linux:disk", "namespace": "default"}, "subscription": "linux", "check": "disk"}]`)
+		case "/api/core/v2/namespaces/default/events/web%201":
+			fmt.Fprint(w, `[]`)
+		case "/api/core/v2/namespaces/default/events":
+			events.Config.Handler.ServeHTTP(w, r)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	var source EventSource = RESTSource{
+		URL:    server.URL + "/",
+		Header: map[string]string{"Authorization": "Bearer token"},
+		Page:   PageOptions{Size: 10},
+	}
+
+	evts, err := source.ListEvents(context.Background(), "default")
+	assert.NoError(err)
+	assert.Len(evts, 30)
+
+	count := 0
+	assert.NoError(source.StreamEvents(context.Background(), "default", func(evt corev2.Event) error {
+		count++
+		return nil
+	}))
+	assert.Equal(30, count)
+
+	entities, err := source.ListEntities(context.Background(), "default")
+	assert.NoError(err)
+	assert.Len(entities, 1)
+	assert.Equal("agent", entities[0].EntityClass)
+
+	silences, err := source.ListSilences(context.Background(), "default")
+	assert.NoError(err)
+	assert.Len(silences, 1)
+	assert.Equal("disk", silences[0].Check)
+
+	namespaces, err := source.ListNamespaces(context.Background())
+	assert.NoError(err)
+	assert.Equal([]string{"default", "production"}, namespaces)
+
+	entityEvents, err := source.(EntityEventSource).ListEntityEvents(context.Background(), "default", "web 1")
+	assert.NoError(err)
+	assert.Empty(entityEvents)
+
+	_, err = source.ListEntities(context.Background(), "production")
+	assert.ErrorContains(err, "404")
+
+	_, err = RESTSource{URL: server.URL}.ListNamespaces(context.Background())
+	assert.ErrorContains(err, "401")
+}
+
+func TestGraphQLSource(t *testing.T) {
+	assert := assert.New(t)
+
+	server := newGraphQLServer(t)
+	defer server.Close()
+
+	// The lean events query serves the GraphQL endpoint, a REST source with Lean set reads it
+	var source EventSource = RESTSource{URL: server.URL, Lean: true}
+	evts, err := source.ListEvents(context.Background(), "default")
+	assert.NoError(err)
+	assert.Len(evts, 4)
+
+	_, err = GraphQLSource{RESTSource: RESTSource{URL: server.URL}}.ListEvents(context.Background(), "missing")
+	assert.ErrorContains(err, `namespace "missing" not found`)
+
+	err = GraphQLSource{RESTSource: RESTSource{URL: server.URL}}.StreamEvents(context.Background(), "missing", func(evt corev2.Event) error {
+		return nil
+	})
+	assert.ErrorContains(err, `namespace "missing" not found`)
+}
+
+func TestFileSource(t *testing.T) {
+	assert := assert.New(t)
+
+	input := `{"type": "Event", "api_version": "core/v2", "metadata": {"namespace": "default"},
+	"spec": {"entity": {"metadata": {"name": "web1", "namespace": "default"}}, "check": {"metadata": {"name": "http"}, "status": 2}}}
+{"type": "Entity", "api_version": "core/v2", "metadata": {"name": "db1", "namespace": "production"}, "spec": {"entity_class": "proxy"}}
+{"type": "Entity", "api_version": "core/v2", "metadata": {"name": "web1", "namespace": "default"}, "spec": {"entity_class": "agent"}}
+{"type": "Silenced", "api_version": "core/v2", "metadata": {"name": "linux:disk", "namespace": "default"}, "spec": {"subscription": "linux", "check": "disk"}}
+`
+	path := filepath.Join(t.TempDir(), "resources.json")
+	assert.NoError(os.WriteFile(path, []byte(input), 0o644))
+
+	var source EventSource = &FileSource{Path: path}
+
+	evts, err := source.ListEvents(context.Background(), "default")
+	assert.NoError(err)
+	assert.Len(evts, 1)
+	assert.Equal(sensu.CheckStateCritical, GetEntitiesStatus(evts)["web1"].Status)
+
+	// The entity resource comes first, the entity of the event is not listed twice
+	entities, err := source.ListEntities(context.Background(), "")
+	assert.NoError(err)
+	assert.Len(entities, 2)
+	assert.Equal("proxy", entities[0].EntityClass)
+	assert.Equal("agent", entities[1].EntityClass)
+
+	silences, err := source.ListSilences(context.Background(), "production")
+	assert.NoError(err)
+	assert.Empty(silences)
+	silences, err = source.ListSilences(context.Background(), "default")
+	assert.NoError(err)
+	assert.Len(silences, 1)
+
+	namespaces, err := source.ListNamespaces(context.Background())
+	assert.NoError(err)
+	assert.Equal([]string{"default", "production"}, namespaces)
+
+	// The file is read again at every call
+	assert.NoError(os.WriteFile(path, []byte("[]"), 0o644))
+	evts, err = source.ListEvents(context.Background(), "default")
+	assert.NoError(err)
+	assert.Empty(evts)
+
+	_, err = (&FileSource{Path: filepath.Join(t.TempDir(), "missing.json")}).ListEvents(context.Background(), "")
+	assert.Error(err)
+}
+
+func TestFileSourceStdin(t *testing.T) {
+	assert := assert.New(t)
+
+	source := &FileSource{Path: "-", Stdin: strings.NewReader(`[{"entity": {"metadata": {"name": "web1"}}, "check": {"metadata": {"name": "http"}}}]`)}
+
+	// Stdin is read once, then kept for the next calls
+	for i := 0; i < 2; i++ {
+		evts, err := source.ListEvents(context.Background(), "")
+		assert.NoError(err)
+		assert.Len(evts, 1)
+	}
+
+	_, err := (&FileSource{Path: "-", Stdin: strings.NewReader("{")}).ListEvents(context.Background(), "")
+	assert.ErrorContains(err, "stdin")
+}
+
+func TestFakeSource(t *testing.T) {
+	assert := assert.New(t)
+
+	evt1 := *corev2.FixtureEvent("localhost", "dummy-check1")
+	evt2 := *corev2.FixtureEvent("localhost2", "dummy-check1")
+	evt2.Namespace = "production"
+	evt2.Entity.Namespace = "production"
+	silence := *corev2.FixtureSilenced("linux:dummy-check1")
+
+	source := &FakeSource{}
+	source.Events = []corev2.Event{evt1, evt2}
+	source.Silences = []corev2.Silenced{silence}
+
+	evts, err := source.ListEvents(context.Background(), "production")
+	assert.NoError(err)
+	assert.Len(evts, 1)
+	assert.Equal("localhost2", evts[0].Entity.Name)
+
+	entities, err := source.ListEntities(context.Background(), "default")
+	assert.NoError(err)
+	assert.Len(entities, 1)
+	assert.Equal("localhost", entities[0].Name)
+
+	silences, err := source.ListSilences(context.Background(), "default")
+	assert.NoError(err)
+	assert.Len(silences, 1)
+
+	namespaces, err := source.ListNamespaces(context.Background())
+	assert.NoError(err)
+	assert.Equal([]string{"default", "production"}, namespaces)
+
+	// An error of fn stops the stream
+	stop := errors.New("stop")
+	count := 0
+	err = source.StreamEvents(context.Background(), "", func(evt corev2.Event) error {
+		count++
+		return stop
+	})
+	assert.ErrorIs(err, stop)
+	assert.Equal(1, count)
+
+	source.Err = errors.New("unavailable")
+	_, err = source.ListEvents(context.Background(), "")
+	assert.ErrorIs(err, source.Err)
+	_, err = source.ListNamespaces(context.Background())
+	assert.ErrorIs(err, source.Err)
+}
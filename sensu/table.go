@@ -0,0 +1,348 @@
+package sensu
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// DefaultColumns : Columns of the tabular output
+var DefaultColumns = []string{"entity", "status", "events", "silenced", "critical", "warning", "unknown", "ok"}
+
+// WideColumns : Columns of the wide output
+var WideColumns = append(append([]string{}, DefaultColumns...), "namespace", "class", "subscriptions", "last-seen", "worst-check")
+
+// DefaultSortBy : Sort order of the tabular output, worst entities first
+var DefaultSortBy = []string{"status", "entity"}
+
+// TableRow : Data available to the columns of the tabular output
+type TableRow struct {
+	Name   string
+	Status EntityStatus
+	Info   EntityInfo
+}
+
+// tableColumn : Column of the tabular output.
+// Compare orders two rows ascending, Descending tells whether the column is sorted descending by default
+type tableColumn struct {
+	Header     string
+	Value      func(row TableRow) string
+	Compare    func(a TableRow, b TableRow) int
+	Descending bool
+}
+
+func counterColumn(header string, counter func(status EntityStatus) int) tableColumn {
+	return tableColumn{
+		Header:     header,
+		Value:      func(row TableRow) string { return strconv.Itoa(counter(row.Status)) },
+		Compare:    func(a TableRow, b TableRow) int { return cmp.Compare(counter(a.Status), counter(b.Status)) },
+		Descending: true,
+	}
+}
+
+func textColumn(header string, text func(row TableRow) string) tableColumn {
+	return tableColumn{
+		Header:  header,
+		Value:   text,
+		Compare: func(a TableRow, b TableRow) int { return strings.Compare(text(a), text(b)) },
+	}
+}
+
+// tableColumns : Every column of the tabular output, by name
+var tableColumns = map[string]tableColumn{
+	"entity": textColumn("Entity", func(row TableRow) string { return row.Name }),
+	"status": {
+		Header: "Status",
+		Value:  func(row TableRow) string { return translateStatus(row.Status.Status) },
+		Compare: func(a TableRow, b TableRow) int {
+			return cmp.Compare(StatusSeverity(a.Status.Status), StatusSeverity(b.Status.Status))
+		},
+		Descending: true,
+	},
+	"events":        counterColumn("Events", func(status EntityStatus) int { return status.Total }),
+	"silenced":      counterColumn("Silenced", func(status EntityStatus) int { return status.Silenced }),
+	"critical":      counterColumn("Critical", func(status EntityStatus) int { return status.Critical }),
+	"warning":       counterColumn("Warning", func(status EntityStatus) int { return status.Warning }),
+	"unknown":       counterColumn("Unknown", func(status EntityStatus) int { return status.Unknown }),
+	"ok":            counterColumn("Ok", func(status EntityStatus) int { return status.Ok }),
+	"namespace":     textColumn("Namespace", func(row TableRow) string { return row.Info.Namespace }),
+	"class":         textColumn("Class", func(row TableRow) string { return row.Info.EntityClass }),
+	"subscriptions": textColumn("Subscriptions", func(row TableRow) string { return strings.Join(row.Info.Subscriptions, ",") }),
+	"last-seen": {
+		Header: "Last Seen",
+		Value: func(row TableRow) string {
+			if row.Info.LastSeen == 0 {
+				return "-"
+			}
+			return time.Unix(row.Info.LastSeen, 0).Format("2006-01-02 15:04:05")
+		},
+		Compare: func(a TableRow, b TableRow) int { return cmp.Compare(a.Info.LastSeen, b.Info.LastSeen) },
+		// Most recently seen first
+		Descending: true,
+	},
+	"worst-check": textColumn("Worst Check", func(row TableRow) string { return row.Info.WorstCheck }),
+	"since": {
+		Header: "Since",
+		Value: func(row TableRow) string {
+			if row.Status.Since == 0 {
+				return "-"
+			}
+			return time.Since(time.Unix(row.Status.Since, 0)).Round(time.Second).String()
+		},
+		// Ascending time in the current status, the oldest Since being the longest
+		Compare: func(a TableRow, b TableRow) int { return cmp.Compare(b.Status.Since, a.Status.Since) },
+		// Longest in their status first
+		Descending: true,
+	},
+	"flaps": counterColumn("Flaps", func(status EntityStatus) int { return status.Flaps }),
+	"failure-ratio": {
+		Header:     "Failure Ratio",
+		Value:      func(row TableRow) string { return fmt.Sprintf("%.0f%%", row.Status.FailureRatio*100) },
+		Compare:    func(a TableRow, b TableRow) int { return cmp.Compare(a.Status.FailureRatio, b.Status.FailureRatio) },
+		Descending: true,
+	},
+	"streak": counterColumn("Streak", func(status EntityStatus) int { return status.FailingStreak }),
+	"recovered": {
+		Header: "Recovered",
+		Value: func(row TableRow) string {
+			if row.Status.Recovered {
+				return "yes"
+			}
+			return "no"
+		},
+		Compare: func(a TableRow, b TableRow) int {
+			return cmp.Compare(strconv.FormatBool(a.Status.Recovered), strconv.FormatBool(b.Status.Recovered))
+		},
+		// Recovered entities first
+		Descending: true,
+	},
+}
+
+// columnAliases : Alternative names accepted for the columns
+var columnAliases = map[string]string{
+	"name":  "entity",
+	"total": "events",
+}
+
+func lookupColumn(name string) (tableColumn, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if alias, ok := columnAliases[name]; ok {
+		name = alias
+	}
+	column, ok := tableColumns[name]
+	if !ok {
+		return tableColumn{}, fmt.Errorf("unknown column %q", name)
+	}
+	return column, nil
+}
+
+// ValidateColumns : Check every column name is known
+func ValidateColumns(names []string) error {
+	for _, name := range names {
+		if _, err := lookupColumn(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sortKey : Column to sort on, and its direction
+type sortKey struct {
+	column     tableColumn
+	descending bool
+}
+
+// parseSortBy : Parse sort keys such as status, name:desc or critical:asc.
+// Without suffix, severities, counters and last seen are sorted descending and texts ascending
+func parseSortBy(keys []string) ([]sortKey, error) {
+	var parsed []sortKey
+	for _, key := range keys {
+		name, direction, _ := strings.Cut(key, ":")
+		column, err := lookupColumn(name)
+		if err != nil {
+			return nil, err
+		}
+
+		descending := column.Descending
+		switch strings.ToLower(direction) {
+		case "":
+		case "asc":
+			descending = false
+		case "desc":
+			descending = true
+		default:
+			return nil, fmt.Errorf("invalid sort direction %q, must be asc or desc", direction)
+		}
+		parsed = append(parsed, sortKey{column: column, descending: descending})
+	}
+	return parsed, nil
+}
+
+// ValidateSortBy : Check every sort key is valid
+func ValidateSortBy(keys []string) error {
+	_, err := parseSortBy(keys)
+	return err
+}
+
+// SortEntities : Sort entities according to sort keys, ties broken by entity name
+func SortEntities(statusMap map[string]EntityStatus, info map[string]EntityInfo, keys []string) ([]string, error) {
+	if len(keys) == 0 {
+		keys = DefaultSortBy
+	}
+	parsed, err := parseSortBy(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]TableRow, 0, len(statusMap))
+	for _, entity := range sortedEntities(statusMap) {
+		rows = append(rows, TableRow{Name: entity, Status: statusMap[entity], Info: info[entity]})
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		for _, key := range parsed {
+			order := key.column.Compare(rows[i], rows[j])
+			if key.descending {
+				order = -order
+			}
+			if order != 0 {
+				return order < 0
+			}
+		}
+		return false
+	})
+
+	entities := make([]string, 0, len(rows))
+	for _, row := range rows {
+		entities = append(entities, row.Name)
+	}
+	return entities, nil
+}
+
+// TabularOptions : Options of the tabular output
+type TabularOptions struct {
+	// Columns to display, DefaultColumns when empty
+	Columns []string
+	// SortBy keys, DefaultSortBy when empty
+	SortBy []string
+	// Info holds the entities details used by the wide columns, as returned by GetEntitiesInfo
+	Info map[string]EntityInfo
+	// Color enables ANSI colouring of the status cells
+	Color bool
+	// Changed entities are flagged with a star in an extra first column, when not nil
+	Changed map[string]bool
+}
+
+// WriteTabularResult : Write the entities status as a table
+func WriteTabularResult(w io.Writer, statusMap map[string]EntityStatus, opts TabularOptions) error {
+	entities, err := SortEntities(statusMap, opts.Info, opts.SortBy)
+	if err != nil {
+		return err
+	}
+
+	lines, err := renderTable(statusMap, entities, opts)
+	if err != nil {
+		return err
+	}
+	for _, line := range lines {
+		if _, err := io.WriteString(w, line+"\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// renderTable : Render the given entities as right aligned, pipe separated, columns.
+// The two first lines are the table header
+func renderTable(statusMap map[string]EntityStatus, entities []string, opts TabularOptions) ([]string, error) {
+	names := opts.Columns
+	if len(names) == 0 {
+		names = DefaultColumns
+	}
+	var columns []tableColumn
+	statusColumn := -1
+	for _, name := range names {
+		column, err := lookupColumn(name)
+		if err != nil {
+			return nil, err
+		}
+		if column.Header == "Status" {
+			statusColumn = len(columns)
+		}
+		columns = append(columns, column)
+	}
+
+	// Cells of every line, and status of every line for colouring
+	var cells [][]string
+	var statuses []int
+	header := make([]string, 0, len(columns)+1)
+	underline := make([]string, 0, len(columns)+1)
+	if opts.Changed != nil {
+		header = append(header, " ")
+		underline = append(underline, " ")
+	}
+	for _, column := range columns {
+		header = append(header, column.Header)
+		underline = append(underline, strings.Repeat("-", utf8.RuneCountInString(column.Header)))
+	}
+	cells = append(cells, header, underline)
+	statuses = append(statuses, -1, -1)
+
+	for _, entity := range entities {
+		row := TableRow{Name: entity, Status: statusMap[entity], Info: opts.Info[entity]}
+		line := make([]string, 0, len(columns)+1)
+		if opts.Changed != nil {
+			marker := " "
+			if opts.Changed[entity] {
+				marker = "*"
+			}
+			line = append(line, marker)
+		}
+		for _, column := range columns {
+			line = append(line, column.Value(row))
+		}
+		cells = append(cells, line)
+		statuses = append(statuses, row.Status.Status)
+	}
+
+	if opts.Changed != nil && statusColumn >= 0 {
+		statusColumn++
+	}
+
+	// Same layout as a tabwriter with a padding of 3, AlignRight and Debug flags
+	widths := make([]int, len(header))
+	for _, line := range cells {
+		for i, cell := range line {
+			widths[i] = max(widths[i], utf8.RuneCountInString(cell)+3)
+		}
+	}
+
+	lines := make([]string, 0, len(cells))
+	for l, line := range cells {
+		var b strings.Builder
+		for i, cell := range line {
+			if i == len(line)-1 {
+				b.WriteString(colorCell(cell, l, i, statusColumn, statuses[l], opts.Color))
+				break
+			}
+			b.WriteString(strings.Repeat(" ", widths[i]-utf8.RuneCountInString(cell)))
+			b.WriteString(colorCell(cell, l, i, statusColumn, statuses[l], opts.Color))
+			b.WriteString("|")
+		}
+		lines = append(lines, b.String())
+	}
+
+	return lines, nil
+}
+
+func colorCell(cell string, line int, column int, statusColumn int, status int, color bool) string {
+	if !color || column != statusColumn || line < 2 {
+		return cell
+	}
+	return colorize(status, cell)
+}
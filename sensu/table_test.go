@@ -0,0 +1,110 @@
+package sensu
+
+import (
+	"bytes"
+	"testing"
+
+	corev2 "github.com/sensu/core/v2"
+	"github.com/sensu/sensu-plugin-sdk/sensu"
+	"github.com/stretchr/testify/assert"
+)
+
+var tableStatusMap = map[string]EntityStatus{
+	"web":       {Status: sensu.CheckStateCritical, Critical: 1, Ok: 2, Total: 3},
+	"db":        {Status: sensu.CheckStateWarning, Warning: 1, Total: 1},
+	"app":       {Status: sensu.CheckStateCritical, Critical: 2, Total: 2},
+	"localhost": {Status: sensu.CheckStateOK, Ok: 1, Total: 1},
+}
+
+func TestWriteTabularResult(t *testing.T) {
+	assert := assert.New(t)
+
+	var buf bytes.Buffer
+	assert.NoError(WriteTabularResult(&buf, tableStatusMap, TabularOptions{Columns: []string{"entity", "status", "critical"}}))
+	assert.Equal(
+		"      Entity|   Status|Critical\n"+
+			"      ------|   ------|--------\n"+
+			"         app|     CRIT|2\n"+
+			"         web|     CRIT|1\n"+
+			"          db|     WARN|0\n"+
+			"   localhost|       OK|0\n",
+		buf.String(),
+	)
+
+	// Status cells are coloured without breaking the alignment, changed entities are flagged
+	buf.Reset()
+	assert.NoError(WriteTabularResult(&buf, map[string]EntityStatus{"db": tableStatusMap["db"], "app": tableStatusMap["app"]}, TabularOptions{
+		Columns: []string{"name", "status"},
+		Color:   true,
+		Changed: map[string]bool{"db": true},
+	}))
+	assert.Equal(
+		"    |   Entity|Status\n"+
+			"    |   ------|------\n"+
+			"    |      app|\033[31mCRIT\033[0m\n"+
+			"   *|       db|\033[33mWARN\033[0m\n",
+		buf.String(),
+	)
+
+	assert.Error(WriteTabularResult(&buf, tableStatusMap, TabularOptions{Columns: []string{"entity", "cpu"}}))
+}
+
+func TestSortEntities(t *testing.T) {
+	assert := assert.New(t)
+
+	entities, err := SortEntities(tableStatusMap, nil, nil)
+	assert.NoError(err)
+	assert.Equal([]string{"app", "web", "db", "localhost"}, entities)
+
+	entities, err = SortEntities(tableStatusMap, nil, []string{"critical:asc", "name:desc"})
+	assert.NoError(err)
+	assert.Equal([]string{"localhost", "db", "web", "app"}, entities)
+
+	entities, err = SortEntities(tableStatusMap, map[string]EntityInfo{"db": {LastSeen: 20}, "web": {LastSeen: 10}}, []string{"last-seen"})
+	assert.NoError(err)
+	assert.Equal([]string{"db", "web", "app", "localhost"}, entities)
+
+	_, err = SortEntities(tableStatusMap, nil, []string{"status:up"})
+	assert.Error(err)
+	assert.Error(ValidateSortBy([]string{"cpu"}))
+	assert.NoError(ValidateColumns(WideColumns))
+}
+
+func TestGetEntitiesInfo(t *testing.T) {
+	assert := assert.New(t)
+
+	entity := corev2.FixtureEntity("web")
+	entity.Subscriptions = []string{"linux", "entity:web"}
+	entity.LastSeen = 1700000000
+
+	disk := corev2.FixtureEvent("web", "disk")
+	disk.Entity = entity
+	disk.Check.Status = sensu.CheckStateWarning
+	cpu := corev2.FixtureEvent("web", "cpu")
+	cpu.Entity = entity
+	cpu.Check.Status = sensu.CheckStateWarning
+	http := corev2.FixtureEvent("web", "http")
+	http.Entity = entity
+	http.Check.Status = sensu.CheckStateCritical
+	http.Check.Silenced = []string{"web:http"}
+	ok := corev2.FixtureEvent("db", "cpu")
+
+	infos := GetEntitiesInfo([]corev2.Event{*disk, *cpu, *http, *ok})
+	assert.Equal(EntityInfo{
+		Namespace:     "default",
+		EntityClass:   "host",
+		Subscriptions: []string{"linux"},
+		LastSeen:      1700000000,
+		// The silenced critical check is ignored, warnings tie on the check name
+		WorstCheck: "cpu",
+	}, infos["web"])
+	assert.Empty(infos["db"].WorstCheck)
+
+	// The aggregator collects the same details as the events are read
+	agg := NewAggregator()
+	for _, evt := range []corev2.Event{*disk, *cpu, *http, *ok} {
+		agg.Add(evt)
+	}
+	assert.Equal(infos, agg.Info("default"))
+	assert.Empty(agg.Info("production"))
+}
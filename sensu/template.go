@@ -0,0 +1,163 @@
+package sensu
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sensu/sensu-plugin-sdk/sensu"
+)
+
+// TemplateMetadata : Context of a collection, available to the template and jsonpath outputs
+type TemplateMetadata struct {
+	Namespace string    `json:"namespace"`
+	Cluster   string    `json:"cluster"`
+	Generated time.Time `json:"generated"`
+}
+
+// TemplateItem : Status of a single entity, available to the template and jsonpath outputs
+type TemplateItem struct {
+	Name string `json:"name"`
+	EntityStatus
+}
+
+// TemplateData : Data model of the template and jsonpath outputs.
+//
+//	metadata: namespace, cluster and generation time
+//	summary:  number of entities in each status
+//	entities: map of entity name to entity status
+//	items:    entities status as a list, worst status first
+type TemplateData struct {
+	Metadata TemplateMetadata        `json:"metadata"`
+	Summary  StatusSummary           `json:"summary"`
+	Entities map[string]EntityStatus `json:"entities"`
+	Items    []TemplateItem          `json:"items"`
+}
+
+// NewTemplateData : Build the template and jsonpath data model from an entities status map
+func NewTemplateData(statusMap map[string]EntityStatus, metadata TemplateMetadata) TemplateData {
+	data := TemplateData{
+		Metadata: metadata,
+		Summary:  GetStatusSummary(statusMap),
+		Entities: statusMap,
+		Items:    []TemplateItem{},
+	}
+	for _, entity := range sortedBySeverity(statusMap) {
+		data.Items = append(data.Items, TemplateItem{Name: entity, EntityStatus: statusMap[entity]})
+	}
+	return data
+}
+
+// statusColors : ANSI color of each status
+var statusColors = map[int]string{
+	sensu.CheckStateOK:       "\033[32m",
+	sensu.CheckStateWarning:  "\033[33m",
+	sensu.CheckStateCritical: "\033[31m",
+}
+
+// colorize : Wrap a text in the ANSI color matching a status
+func colorize(status int, text string) string {
+	color, ok := statusColors[status]
+	if !ok {
+		// Unknown status in magenta
+		color = "\033[35m"
+	}
+	return color + text + "\033[0m"
+}
+
+// pad : Right pad a text with spaces up to the given width
+func pad(width int, text string) string {
+	if n := utf8.RuneCountInString(text); n < width {
+		return text + strings.Repeat(" ", width-n)
+	}
+	return text
+}
+
+// TemplateFuncs : Helper functions available to the template output
+var TemplateFuncs = template.FuncMap{
+	"statusName": StatusName,
+	"pad":        pad,
+	"color":      colorize,
+}
+
+// ParseTemplate : Parse a template output definition
+func ParseTemplate(text string) (*template.Template, error) {
+	return template.New("output").Funcs(TemplateFuncs).Parse(text)
+}
+
+// WriteTemplateResult : Write the entities status rendered by a Go text/template
+func WriteTemplateResult(w io.Writer, tmpl *template.Template, data TemplateData) error {
+	return tmpl.Execute(w, data)
+}
+
+// WriteJSONPathResult : Write the entities status rendered by a JSONPath template
+func WriteJSONPathResult(w io.Writer, path *JSONPath, data TemplateData) error {
+	return executeJSONPath(w, path, data)
+}
+
+// executeJSONPath : Render a JSONPath template on the JSON representation of a value
+func executeJSONPath(w io.Writer, path *JSONPath, value interface{}) error {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	var doc interface{}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return err
+	}
+
+	return path.Execute(w, doc)
+}
+
+// TemplateFormatter : Output format rendered by a Go text/template
+type TemplateFormatter struct {
+	Template *template.Template
+}
+
+// Format : Render the entities status with the template
+func (f TemplateFormatter) Format(w io.Writer, data FormatData) error {
+	return WriteTemplateResult(w, f.Template, data.TemplateData())
+}
+
+// StatusOnly : Templates only see the entities status
+func (f TemplateFormatter) StatusOnly() bool {
+	return true
+}
+
+// FormatDiff : Render the difference between two snapshots with the template
+func (f TemplateFormatter) FormatDiff(w io.Writer, diff SnapshotDiff) error {
+	return f.Template.Execute(w, diff)
+}
+
+// FormatReport : Render the availability report with the template
+func (f TemplateFormatter) FormatReport(w io.Writer, report AvailabilityReport) error {
+	return f.Template.Execute(w, report)
+}
+
+// JSONPathFormatter : Output format rendered by a JSONPath template
+type JSONPathFormatter struct {
+	Path *JSONPath
+}
+
+// Format : Render the entities status with the JSONPath template
+func (f JSONPathFormatter) Format(w io.Writer, data FormatData) error {
+	return WriteJSONPathResult(w, f.Path, data.TemplateData())
+}
+
+// StatusOnly : Templates only see the entities status
+func (f JSONPathFormatter) StatusOnly() bool {
+	return true
+}
+
+// FormatDiff : Render the difference between two snapshots with the JSONPath template
+func (f JSONPathFormatter) FormatDiff(w io.Writer, diff SnapshotDiff) error {
+	return executeJSONPath(w, f.Path, diff)
+}
+
+// FormatReport : Render the availability report with the JSONPath template
+func (f JSONPathFormatter) FormatReport(w io.Writer, report AvailabilityReport) error {
+	return executeJSONPath(w, f.Path, report)
+}
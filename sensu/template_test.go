@@ -0,0 +1,73 @@
+package sensu
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/sensu/sensu-plugin-sdk/sensu"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewTemplateData(t *testing.T) {
+	assert := assert.New(t)
+
+	data := NewTemplateData(map[string]EntityStatus{
+		"localhost":  {Status: sensu.CheckStateOK, Ok: 1, Total: 1},
+		"localhost2": {Status: sensu.CheckStateCritical, Critical: 1, Total: 1},
+	}, TemplateMetadata{
+		Namespace: "default",
+		Cluster:   "prod",
+		Generated: time.Unix(1700000000, 0).UTC(),
+	})
+
+	assert.Equal(StatusSummary{Total: 2, Critical: 1, Ok: 1}, data.Summary)
+	assert.Len(data.Entities, 2)
+	// Worst entities first
+	assert.Equal("localhost2", data.Items[0].Name)
+	assert.Equal(sensu.CheckStateCritical, data.Items[0].Status)
+	assert.Equal("localhost", data.Items[1].Name)
+}
+
+func TestWriteTemplateResult(t *testing.T) {
+	assert := assert.New(t)
+
+	tmpl, err := ParseTemplate(`{{.Metadata.Namespace}}: {{.Summary.Critical}}/{{.Summary.Total}}
+{{range .Items}}{{pad 12 .Name}}{{statusName .Status}}
+{{end}}{{color 2 "down"}}`)
+	assert.NoError(err)
+
+	data := NewTemplateData(map[string]EntityStatus{
+		"localhost":  {Status: sensu.CheckStateOK, Ok: 1, Total: 1},
+		"localhost2": {Status: sensu.CheckStateCritical, Critical: 1, Total: 1},
+	}, TemplateMetadata{
+		Namespace: "default",
+		Cluster:   "prod",
+		Generated: time.Unix(1700000000, 0).UTC(),
+	})
+	var buf bytes.Buffer
+	assert.NoError(WriteTemplateResult(&buf, tmpl, data))
+	assert.Equal("default: 1/2\nlocalhost2  CRIT\nlocalhost   OK\n\033[31mdown\033[0m", buf.String())
+
+	_, err = ParseTemplate(`{{.Metadata`)
+	assert.Error(err)
+}
+
+func TestWriteJSONPathResult(t *testing.T) {
+	assert := assert.New(t)
+
+	path, err := ParseJSONPath(`{.metadata.cluster} {.summary.total} {.items[?(@.status==2)].name} {.entities.localhost.ok}`)
+	assert.NoError(err)
+
+	data := NewTemplateData(map[string]EntityStatus{
+		"localhost":  {Status: sensu.CheckStateOK, Ok: 1, Total: 1},
+		"localhost2": {Status: sensu.CheckStateCritical, Critical: 1, Total: 1},
+	}, TemplateMetadata{
+		Namespace: "default",
+		Cluster:   "prod",
+		Generated: time.Unix(1700000000, 0).UTC(),
+	})
+	var buf bytes.Buffer
+	assert.NoError(WriteJSONPathResult(&buf, path, data))
+	assert.Equal("prod 2 localhost2 1", buf.String())
+}
@@ -0,0 +1,81 @@
+package sensu
+
+import (
+	"encoding/json"
+	"io"
+
+	"gopkg.in/yaml.v2"
+)
+
+// WrappedType : Type of the resources in sensuctl envelopes
+const WrappedType = "EntityStatus"
+
+// WrappedAPIVersion : API version of the resources in sensuctl envelopes
+const WrappedAPIVersion = "entities-status/v1"
+
+// WrappedMetadata : Metadata of a wrapped entity status
+type WrappedMetadata struct {
+	Name      string `json:"name" yaml:"name"`
+	Namespace string `json:"namespace" yaml:"namespace"`
+}
+
+// WrappedEntityStatus : Entity status wrapped following the sensuctl resource envelope convention
+type WrappedEntityStatus struct {
+	Type       string          `json:"type" yaml:"type"`
+	APIVersion string          `json:"api_version" yaml:"api_version"`
+	Metadata   WrappedMetadata `json:"metadata" yaml:"metadata"`
+	Spec       EntityStatus    `json:"spec" yaml:"spec"`
+}
+
+// WrapEntitiesStatus : Wrap every entity status in a sensuctl envelope, sorted by entity name
+func WrapEntitiesStatus(statusMap map[string]EntityStatus, namespace string) []WrappedEntityStatus {
+	wrapped := make([]WrappedEntityStatus, 0, len(statusMap))
+
+	for _, entity := range sortedEntities(statusMap) {
+		wrapped = append(wrapped, WrappedEntityStatus{
+			Type:       WrappedType,
+			APIVersion: WrappedAPIVersion,
+			Metadata: WrappedMetadata{
+				Name:      entity,
+				Namespace: namespace,
+			},
+			Spec: statusMap[entity],
+		})
+	}
+
+	return wrapped
+}
+
+// WriteWrappedJSON : Write the entities status as a stream of sensuctl wrapped-json resources
+func WriteWrappedJSON(w io.Writer, statusMap map[string]EntityStatus, namespace string) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+
+	for _, resource := range WrapEntitiesStatus(statusMap, namespace) {
+		if err := enc.Encode(resource); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WriteWrappedYAML : Write the entities status as sensuctl yaml resources, one document per entity
+func WriteWrappedYAML(w io.Writer, statusMap map[string]EntityStatus, namespace string) error {
+	for i, resource := range WrapEntitiesStatus(statusMap, namespace) {
+		data, err := yaml.Marshal(resource)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			if _, err := io.WriteString(w, "---\n"); err != nil {
+				return err
+			}
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,81 @@
+package sensu
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/sensu/sensu-plugin-sdk/sensu"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+func TestWrapEntitiesStatus(t *testing.T) {
+	assert := assert.New(t)
+
+	statusMap := map[string]EntityStatus{
+		"localhost2": {Status: sensu.CheckStateCritical, Critical: 1, Total: 1},
+		"localhost":  {Status: sensu.CheckStateOK, Ok: 2, Total: 2},
+	}
+
+	wrapped := WrapEntitiesStatus(statusMap, "default")
+
+	assert.Len(wrapped, 2)
+	assert.Equal("EntityStatus", wrapped[0].Type)
+	assert.Equal("localhost", wrapped[0].Metadata.Name)
+	assert.Equal("default", wrapped[0].Metadata.Namespace)
+	assert.Equal(2, wrapped[0].Spec.Ok)
+	assert.Equal("localhost2", wrapped[1].Metadata.Name)
+}
+
+func TestWriteWrappedJSON(t *testing.T) {
+	assert := assert.New(t)
+
+	statusMap := map[string]EntityStatus{
+		"localhost2": {Status: sensu.CheckStateCritical, Critical: 1, Total: 1},
+		"localhost":  {Status: sensu.CheckStateOK, Ok: 2, Total: 2},
+	}
+	var buf bytes.Buffer
+	assert.NoError(WriteWrappedJSON(&buf, statusMap, "default"))
+
+	// One JSON resource after the other, as sensuctl does
+	dec := json.NewDecoder(&buf)
+	var resources []map[string]interface{}
+	for {
+		var resource map[string]interface{}
+		if err := dec.Decode(&resource); err == io.EOF {
+			break
+		} else {
+			assert.NoError(err)
+		}
+		resources = append(resources, resource)
+	}
+
+	assert.Len(resources, 2)
+	assert.Equal("EntityStatus", resources[1]["type"])
+	assert.Equal("entities-status/v1", resources[1]["api_version"])
+	assert.Equal("localhost2", resources[1]["metadata"].(map[string]interface{})["name"])
+	assert.Equal(float64(2), resources[1]["spec"].(map[string]interface{})["status"])
+}
+
+func TestWriteWrappedYAML(t *testing.T) {
+	assert := assert.New(t)
+
+	statusMap := map[string]EntityStatus{
+		"localhost2": {Status: sensu.CheckStateCritical, Critical: 1, Total: 1},
+		"localhost":  {Status: sensu.CheckStateOK, Ok: 2, Total: 2},
+	}
+	var buf bytes.Buffer
+	assert.NoError(WriteWrappedYAML(&buf, statusMap, "default"))
+
+	documents := strings.Split(buf.String(), "---\n")
+	assert.Len(documents, 2)
+
+	var resource WrappedEntityStatus
+	assert.NoError(yaml.Unmarshal([]byte(documents[1]), &resource))
+	assert.Equal("EntityStatus", resource.Type)
+	assert.Equal("localhost2", resource.Metadata.Name)
+	assert.Equal(1, resource.Spec.Critical)
+}
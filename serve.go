@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	customSensu "las/accs/entities-status/sensu"
+
+	"github.com/apex/log"
+	"github.com/sensu/sensu-go/types"
+	"github.com/sensu/sensu-plugin-sdk/sensu"
+)
+
+// defaultServeInterval : Collection interval of the serve subcommand when --watch is not set
+const defaultServeInterval = time.Minute
+
+// statusServer : Keep the last collection result and expose it over HTTP
+type statusServer struct {
+	mu        sync.RWMutex
+	statusMap map[string]customSensu.EntityStatus
+	groups    map[string]customSensu.GroupStatus
+	metrics   []byte
+	updated   time.Time
+	err       error
+}
+
+func runServe() {
+	plugin := sensu.NewGoCheck(&config.PluginConfig, options, checkArgs, executeServe, false)
+	plugin.Execute()
+}
+
+// executeServe : Collect the entities status on an interval and serve it until SIGINT or SIGTERM
+func executeServe(event *types.Event) (int, error) {
+	setLogLevel()
+	ctx := log.WithFields(log.Fields{
+		"file":     "serve.go",
+		"function": "executeServe",
+	})
+
+	interval := config.watchInterval
+	if interval == 0 {
+		interval = defaultServeInterval
+	}
+
+	sigCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	server := &statusServer{}
+	go server.collect(sigCtx, interval)
+
+	httpServer := &http.Server{
+		Addr:              config.Listen,
+		Handler:           server.routes(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		ctx.Infof("Listening on %s", config.Listen)
+		errs <- httpServer.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		return sensu.CheckStateCritical, err
+	case <-sigCtx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return sensu.CheckStateCritical, err
+	}
+
+	return sensu.CheckStateOK, nil
+}
+
+// collect : Refresh the server data every interval until the context is done
+func (s *statusServer) collect(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		// The events are not kept, only the group of their entity
+		membership := make(map[string]string)
+		agg, err := collectStatus(ctx, func(evt types.Event) {
+			if evt.Entity == nil {
+				return
+			}
+			if _, ok := membership[evt.Entity.Name]; !ok {
+				membership[evt.Entity.Name] = customSensu.GetEntityGroup(evt.Entity, config.GroupLabel)
+			}
+		})
+		s.refresh(agg, membership, err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// refresh : Replace the server data with a new collection result, the aggregated events and the group
+// of their entities. Previous data is kept on error so a backend hiccup does not blank the page
+func (s *statusServer) refresh(agg *customSensu.Aggregator, membership map[string]string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.err = err
+	if err != nil {
+		log.WithError(err).Error("Error refreshing entities status")
+		return
+	}
+
+	s.statusMap = agg.Snapshot(config.Namespace)
+	if err := recordHistory(agg, s.statusMap); err != nil {
+		log.WithError(err).Error("Error recording history")
+	}
+	s.groups = customSensu.GroupEntitiesStatus(membership, s.statusMap)
+
+	var metrics bytes.Buffer
+	if err := customSensu.WriteAggregatorMetrics(&metrics, config.Namespace, agg); err != nil {
+		log.WithError(err).Error("Error rendering metrics")
+	}
+	s.metrics = metrics.Bytes()
+	s.updated = time.Now()
+}
+
+func (s *statusServer) routes() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/entities", s.handleEntities)
+	mux.HandleFunc("GET /api/entities/{name}", s.handleEntity)
+	mux.HandleFunc("GET /api/groups", s.handleGroups)
+	mux.HandleFunc("GET /metrics", s.handleMetrics)
+	mux.HandleFunc("GET /{$}", s.handleIndex)
+	return mux
+}
+
+// ready : Reply with an error until the first collection succeeded
+func (s *statusServer) ready(w http.ResponseWriter) bool {
+	if s.updated.IsZero() {
+		msg := "entities status not collected yet"
+		if s.err != nil {
+			msg = s.err.Error()
+		}
+		http.Error(w, msg, http.StatusServiceUnavailable)
+		return false
+	}
+	return true
+}
+
+func (s *statusServer) handleEntities(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.ready(w) {
+		writeJSON(w, s.statusMap)
+	}
+}
+
+func (s *statusServer) handleEntity(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if !s.ready(w) {
+		return
+	}
+
+	status, ok := s.statusMap[r.PathValue("name")]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	writeJSON(w, status)
+}
+
+func (s *statusServer) handleGroups(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.ready(w) {
+		writeJSON(w, s.groups)
+	}
+}
+
+func (s *statusServer) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.ready(w) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_, _ = w.Write(s.metrics)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	if err := enc.Encode(v); err != nil {
+		log.WithError(err).Error("Error encoding JSON response")
+	}
+}
+
+func (s *statusServer) handleIndex(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	page := customSensu.NewHTMLPage(s.statusMap, config.Namespace, s.updated)
+	page.Refresh = int(defaultServeInterval.Seconds())
+	if config.watchInterval > 0 {
+		page.Refresh = int(config.watchInterval.Seconds())
+	}
+	page.Err = s.err
+	page.EntityLink = "api/entities/"
+	for name, group := range s.groups {
+		page.Groups = append(page.Groups, customSensu.HTMLGroupRow{Name: name, GroupStatus: group})
+	}
+	sort.Slice(page.Groups, func(i, j int) bool { return page.Groups[i].Name < page.Groups[j].Name })
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := customSensu.WriteHTMLPage(w, page); err != nil {
+		log.WithError(err).Error("Error rendering status page")
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	customSensu "las/accs/entities-status/sensu"
+
+	"github.com/sensu/sensu-go/types"
+	"github.com/sensu/sensu-plugin-sdk/sensu"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatusServer(t *testing.T) {
+	assert := assert.New(t)
+	defer func(saved Config) { config = saved }(config)
+
+	config.Namespace = "default"
+	server := &statusServer{}
+	handler := server.routes()
+
+	// Nothing collected yet
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/entities", nil))
+	assert.Equal(http.StatusServiceUnavailable, rec.Code)
+
+	evt := *types.FixtureEvent("localhost", "dummy-check1")
+	evt.Check.Status = sensu.CheckStateCritical
+	agg := customSensu.NewAggregator()
+	agg.Add(evt)
+	server.refresh(agg, customSensu.GetEntitiesGroups([]types.Event{evt}, ""), nil)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/entities", nil))
+	assert.Equal(http.StatusOK, rec.Code)
+	var statusMap map[string]customSensu.EntityStatus
+	assert.NoError(json.Unmarshal(rec.Body.Bytes(), &statusMap))
+	assert.Equal(sensu.CheckStateCritical, statusMap["localhost"].Status)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/entities/localhost", nil))
+	assert.Equal(http.StatusOK, rec.Code)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/entities/unknown", nil))
+	assert.Equal(http.StatusNotFound, rec.Code)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/groups", nil))
+	assert.Equal(http.StatusOK, rec.Code)
+	var groups map[string]customSensu.GroupStatus
+	assert.NoError(json.Unmarshal(rec.Body.Bytes(), &groups))
+	assert.Len(groups, 1)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(http.StatusOK, rec.Code)
+	assert.Contains(rec.Body.String(), `sensu_entity_status{namespace="default",entity="localhost"} 2`)
+
+	// A failed collection keeps the previous data
+	server.refresh(nil, nil, errors.New("backend unavailable"))
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(http.StatusOK, rec.Code)
+	assert.Contains(rec.Body.String(), "localhost")
+	assert.Contains(rec.Body.String(), "backend unavailable")
+
+	// The status page is the html report, with the groups, a refresh and links to the entities
+	assert.Contains(rec.Body.String(), `<meta http-equiv="refresh" content="60">`)
+	assert.Contains(rec.Body.String(), `<span class="s2">1 critical</span>`)
+	assert.Contains(rec.Body.String(), `<tr class="s2"><td>host</td><td>CRIT</td><td>1</td>`)
+	assert.Contains(rec.Body.String(), `<td><a href="api/entities/localhost">localhost</a></td>`)
+}
@@ -0,0 +1,20 @@
+//go:build !linux && !darwin
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+var errTerminalNotSupported = errors.New("terminal control is not supported on this platform")
+
+// makeRaw : Put the terminal in raw mode, returning a function restoring its previous state
+func makeRaw(f *os.File) (func(), error) {
+	return nil, errTerminalNotSupported
+}
+
+// terminalSize : Get the terminal width and height
+func terminalSize(f *os.File) (int, int, error) {
+	return 0, 0, errTerminalNotSupported
+}
@@ -0,0 +1,557 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	customSensu "las/accs/entities-status/sensu"
+
+	"github.com/sensu/sensu-go/types"
+	"github.com/sensu/sensu-plugin-sdk/sensu"
+)
+
+// defaultTopInterval : Refresh interval of the top subcommand when --watch is not set
+const defaultTopInterval = 10 * time.Second
+
+// topColumns : Columns of the entities table, in display order
+var topColumns = []string{"Entity", "Status", "Events", "Silenced", "Critical", "Warning", "Unknown", "Ok"}
+
+type topAction int
+
+const (
+	topActionNone topAction = iota
+	topActionQuit
+	topActionRefresh
+)
+
+// topView : State of the interactive dashboard
+type topView struct {
+	events       []types.Event
+	statusMap    map[string]customSensu.EntityStatus
+	updated      time.Time
+	err          error
+	sortColumn   int
+	sortDesc     bool
+	search       string
+	searching    bool
+	hideOK       bool
+	hideSilenced bool
+	selected     string
+	detail       string
+	cursor       int
+	offset       int
+	height       int
+}
+
+func newTopView() *topView {
+	// Worst entities first
+	return &topView{sortColumn: 1, sortDesc: true, height: 24}
+}
+
+func runTop() {
+	plugin := sensu.NewGoCheck(&config.PluginConfig, options, checkArgs, executeTop, false)
+	plugin.Execute()
+}
+
+// executeTop : Run the interactive dashboard until the user quits
+func executeTop(event *types.Event) (int, error) {
+	setLogLevel()
+
+	interval := config.watchInterval
+	if interval == 0 {
+		interval = defaultTopInterval
+	}
+
+	if config.Input == "-" {
+		return sensu.CheckStateCritical, errors.New("top reads its keys from stdin, --input - is not supported")
+	}
+
+	restore, err := makeRaw(os.Stdin)
+	if err != nil {
+		return sensu.CheckStateCritical, fmt.Errorf("top requires an interactive terminal: %v", err)
+	}
+	defer restore()
+
+	// Alternate screen buffer, hidden cursor
+	fmt.Print("\033[?1049h\033[?25l")
+	defer fmt.Print("\033[?25h\033[?1049l")
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	keys := make(chan string)
+	go readKeys(os.Stdin, keys)
+
+	type refreshResult struct {
+		events []types.Event
+		err    error
+	}
+	results := make(chan refreshResult, 1)
+	fetching := false
+	fetch := func() {
+		if fetching {
+			return
+		}
+		fetching = true
+		go func() {
+			evts, err := collectEvents(ctx)
+			results <- refreshResult{events: evts, err: err}
+		}()
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	// Redraw regularly to follow terminal resizes
+	redraw := time.NewTicker(time.Second)
+	defer redraw.Stop()
+
+	view := newTopView()
+	fetch()
+	for {
+		view.draw()
+
+		select {
+		case <-ctx.Done():
+			return sensu.CheckStateOK, nil
+		case key, ok := <-keys:
+			if !ok {
+				return sensu.CheckStateOK, nil
+			}
+			switch view.handleKey(key) {
+			case topActionQuit:
+				return sensu.CheckStateOK, nil
+			case topActionRefresh:
+				fetch()
+			}
+		case res := <-results:
+			fetching = false
+			view.setEvents(res.events, res.err)
+		case <-ticker.C:
+			fetch()
+		case <-redraw.C:
+		}
+	}
+}
+
+// readKeys : Decode the terminal input into key names sent on the keys channel
+func readKeys(f *os.File, keys chan<- string) {
+	defer close(keys)
+
+	escapes := map[string]string{
+		"\x1b[A":  "up",
+		"\x1b[B":  "down",
+		"\x1b[C":  "right",
+		"\x1b[D":  "left",
+		"\x1b[H":  "home",
+		"\x1b[F":  "end",
+		"\x1b[5~": "pgup",
+		"\x1b[6~": "pgdown",
+	}
+
+	buf := make([]byte, 64)
+	for {
+		n, err := f.Read(buf)
+		if err != nil || n == 0 {
+			return
+		}
+		data := buf[:n]
+
+		if data[0] == 0x1b {
+			if n == 1 {
+				keys <- "esc"
+			} else if key, ok := escapes[string(data)]; ok {
+				keys <- key
+			}
+			continue
+		}
+
+		for len(data) > 0 {
+			r, size := utf8.DecodeRune(data)
+			data = data[size:]
+			switch r {
+			case '\r', '\n':
+				keys <- "enter"
+			case 0x7f, 0x08:
+				keys <- "backspace"
+			case 0x03:
+				keys <- "ctrl-c"
+			default:
+				if unicode.IsPrint(r) {
+					keys <- string(r)
+				}
+			}
+		}
+	}
+}
+
+// setEvents : Replace the dashboard data with a new collection result
+func (v *topView) setEvents(events []types.Event, err error) {
+	v.err = err
+	if err != nil {
+		// Keep showing the previous data
+		return
+	}
+	v.events = events
+	v.statusMap = customSensu.GetEntitiesStatus(events)
+	v.updated = time.Now()
+}
+
+// entities : Entities to display, filtered and sorted
+func (v *topView) entities() []string {
+	var entities []string
+	for entity, status := range v.statusMap {
+		if v.hideOK && status.Status == sensu.CheckStateOK {
+			continue
+		}
+		if v.hideSilenced && status.Total > 0 && status.Silenced == status.Total {
+			continue
+		}
+		if len(v.search) > 0 && !strings.Contains(strings.ToLower(entity), strings.ToLower(v.search)) {
+			continue
+		}
+		entities = append(entities, entity)
+	}
+
+	sort.SliceStable(entities, func(i, j int) bool {
+		a, b := entities[i], entities[j]
+		cmp := compareTopColumn(v.sortColumn, a, v.statusMap[a], b, v.statusMap[b])
+		if cmp == 0 {
+			return a < b
+		}
+		if v.sortDesc {
+			return cmp > 0
+		}
+		return cmp < 0
+	})
+
+	return entities
+}
+
+func compareTopColumn(column int, nameA string, a customSensu.EntityStatus, nameB string, b customSensu.EntityStatus) int {
+	var valueA, valueB int
+	switch column {
+	case 0:
+		return strings.Compare(nameA, nameB)
+	case 1:
+		valueA, valueB = customSensu.StatusSeverity(a.Status), customSensu.StatusSeverity(b.Status)
+	case 2:
+		valueA, valueB = a.Total, b.Total
+	case 3:
+		valueA, valueB = a.Silenced, b.Silenced
+	case 4:
+		valueA, valueB = a.Critical, b.Critical
+	case 5:
+		valueA, valueB = a.Warning, b.Warning
+	case 6:
+		valueA, valueB = a.Unknown, b.Unknown
+	case 7:
+		valueA, valueB = a.Ok, b.Ok
+	}
+	return valueA - valueB
+}
+
+// entityEvents : Events of the entity being drilled down, worst first
+func (v *topView) entityEvents() []types.Event {
+	var events []types.Event
+	for _, evt := range v.events {
+		if evt.Entity == nil || evt.Check == nil || evt.Entity.Name != v.detail {
+			continue
+		}
+		if v.hideSilenced && evt.IsSilenced() {
+			continue
+		}
+		if v.hideOK && evt.Check.Status == sensu.CheckStateOK {
+			continue
+		}
+		events = append(events, evt)
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		a, b := customSensu.StatusSeverity(int(events[i].Check.Status)), customSensu.StatusSeverity(int(events[j].Check.Status))
+		if a != b {
+			return a > b
+		}
+		return events[i].Check.Name < events[j].Check.Name
+	})
+
+	return events
+}
+
+// handleKey : Update the dashboard state according to a key press
+func (v *topView) handleKey(key string) topAction {
+	if key == "ctrl-c" {
+		return topActionQuit
+	}
+
+	if v.searching {
+		switch key {
+		case "enter":
+			v.searching = false
+		case "esc":
+			v.searching = false
+			v.search = ""
+		case "backspace":
+			if len(v.search) > 0 {
+				_, size := utf8.DecodeLastRuneInString(v.search)
+				v.search = v.search[:len(v.search)-size]
+			}
+		default:
+			if utf8.RuneCountInString(key) == 1 {
+				v.search += key
+			}
+		}
+		return topActionNone
+	}
+
+	page := v.bodyHeight()
+	switch key {
+	case "q":
+		if len(v.detail) > 0 {
+			v.leaveDetail()
+			return topActionNone
+		}
+		return topActionQuit
+	case "esc", "left", "backspace":
+		if len(v.detail) > 0 {
+			v.leaveDetail()
+		} else if len(v.search) > 0 {
+			v.search = ""
+		}
+	case "up", "k":
+		v.move(-1)
+	case "down", "j":
+		v.move(1)
+	case "pgup":
+		v.move(-page)
+	case "pgdown":
+		v.move(page)
+	case "home", "g":
+		v.move(-v.cursor)
+	case "end", "G":
+		v.move(v.rowCount())
+	case "enter", "right":
+		if len(v.detail) == 0 && len(v.selected) > 0 {
+			v.detail = v.selected
+			v.cursor, v.offset = 0, 0
+		}
+	case "/":
+		if len(v.detail) == 0 {
+			v.searching = true
+		}
+	case "o":
+		v.hideOK = !v.hideOK
+	case "m":
+		v.hideSilenced = !v.hideSilenced
+	case "r":
+		return topActionRefresh
+	default:
+		if len(key) == 1 && key[0] >= '1' && key[0] < '1'+byte(len(topColumns)) && len(v.detail) == 0 {
+			column := int(key[0] - '1')
+			if column == v.sortColumn {
+				v.sortDesc = !v.sortDesc
+			} else {
+				// Names are sorted alphabetically, numbers greatest first
+				v.sortColumn = column
+				v.sortDesc = column != 0
+			}
+		}
+	}
+
+	return topActionNone
+}
+
+func (v *topView) rowCount() int {
+	if len(v.detail) > 0 {
+		return len(v.entityEvents())
+	}
+	return len(v.entities())
+}
+
+func (v *topView) move(delta int) {
+	v.cursor += delta
+	if len(v.detail) > 0 {
+		return
+	}
+	entities := v.entities()
+	v.cursor = clamp(v.cursor, 0, len(entities)-1)
+	if len(entities) > 0 {
+		v.selected = entities[v.cursor]
+	}
+}
+
+func (v *topView) leaveDetail() {
+	v.detail = ""
+	v.cursor, v.offset = 0, 0
+}
+
+// bodyHeight : Number of table rows fitting on screen, besides the title, header and help lines
+func (v *topView) bodyHeight() int {
+	return max(v.height-6, 1)
+}
+
+func clamp(value int, low int, high int) int {
+	if value > high {
+		value = high
+	}
+	if value < low {
+		value = low
+	}
+	return value
+}
+
+func (v *topView) draw() {
+	width, height, err := terminalSize(os.Stdout)
+	if err != nil {
+		width, height = 80, 24
+	}
+	v.height = height
+
+	// Overwrite the previous screen line by line to avoid flickering
+	lines := v.render(width)
+	fmt.Print("\033[H" + strings.Join(lines, "\033[K\r\n") + "\033[K\033[J")
+}
+
+// render : Build the screen content, one string per line
+func (v *topView) render(width int) []string {
+	var rows []string
+	var header []string
+	if len(v.detail) > 0 {
+		header, rows = v.renderDetail()
+	} else {
+		header, rows = v.renderList()
+	}
+
+	title := fmt.Sprintf("%s - %s - %s", config.PluginConfig.Name, config.Namespace, sourceName())
+	if !v.updated.IsZero() {
+		title += " - updated " + v.updated.Format("15:04:05")
+	}
+	if v.err != nil {
+		title += " - error: " + v.err.Error()
+	}
+
+	lines := []string{truncate(title, width), ""}
+	for _, line := range header {
+		lines = append(lines, truncate(line, width))
+	}
+
+	// Keep the cursor in the visible window
+	body := v.bodyHeight()
+	v.cursor = clamp(v.cursor, 0, len(rows)-1)
+	if v.cursor < v.offset {
+		v.offset = v.cursor
+	}
+	if v.cursor >= v.offset+body {
+		v.offset = v.cursor - body + 1
+	}
+	v.offset = clamp(v.offset, 0, max(len(rows)-body, 0))
+
+	for i := v.offset; i < len(rows) && i < v.offset+body; i++ {
+		line := truncate(rows[i], width)
+		if i == v.cursor {
+			line = "\033[7m" + line + "\033[0m"
+		}
+		lines = append(lines, line)
+	}
+	for len(lines) < body+4 {
+		lines = append(lines, "")
+	}
+
+	lines = append(lines, truncate(v.statusLine(), width))
+	return lines
+}
+
+func (v *topView) renderList() ([]string, []string) {
+	entities := v.entities()
+
+	// Follow the selected entity across refreshes and sorts
+	for i, entity := range entities {
+		if entity == v.selected {
+			v.cursor = i
+			break
+		}
+	}
+	v.cursor = clamp(v.cursor, 0, len(entities)-1)
+	if len(entities) > 0 {
+		v.selected = entities[v.cursor]
+	}
+
+	lines := customSensu.TabularLines(v.statusMap, entities)
+	return lines[:2], lines[2:]
+}
+
+func (v *topView) renderDetail() ([]string, []string) {
+	var buf bytes.Buffer
+
+	w := tabwriter.NewWriter(&buf, 1, 1, 3, ' ', 0)
+	fmt.Fprintln(w, "Check\tStatus\tSilenced\tOccurrences\tExecuted\tOutput")
+	fmt.Fprintln(w, "-----\t------\t--------\t-----------\t--------\t------")
+	for _, evt := range v.entityEvents() {
+		silenced := ""
+		if evt.IsSilenced() {
+			silenced = "yes"
+		}
+		output, _, _ := strings.Cut(strings.TrimSpace(evt.Check.Output), "\n")
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\n",
+			evt.Check.Name,
+			customSensu.StatusName(int(evt.Check.Status)),
+			silenced,
+			evt.Check.Occurrences,
+			time.Unix(evt.Check.Executed, 0).Format("2006-01-02 15:04:05"),
+			output,
+		)
+	}
+	w.Flush()
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	header := []string{"Events of " + v.detail}
+	header = append(header, lines[:2]...)
+	return header, lines[2:]
+}
+
+func (v *topView) statusLine() string {
+	if v.searching {
+		return "/" + v.search + "_"
+	}
+
+	var filters []string
+	if len(v.search) > 0 {
+		filters = append(filters, "search: "+v.search)
+	}
+	if v.hideOK {
+		filters = append(filters, "OK hidden")
+	}
+	if v.hideSilenced {
+		filters = append(filters, "silenced hidden")
+	}
+
+	help := "q quit  ↑↓ move  ⏎ events  / search  1-8 sort  o OK  m silenced  r refresh"
+	if len(v.detail) > 0 {
+		help = "esc back  ↑↓ move  o OK  m silenced  r refresh"
+	} else {
+		order := "asc"
+		if v.sortDesc {
+			order = "desc"
+		}
+		filters = append(filters, fmt.Sprintf("sort: %s %s", topColumns[v.sortColumn], order))
+	}
+
+	return help + "  [" + strings.Join(filters, ", ") + "]"
+}
+
+func truncate(line string, width int) string {
+	if width <= 0 || utf8.RuneCountInString(line) <= width {
+		return line
+	}
+	return string([]rune(line)[:width])
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/sensu/sensu-go/types"
+	"github.com/sensu/sensu-plugin-sdk/sensu"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTopViewEntities(t *testing.T) {
+	assert := assert.New(t)
+
+	evt1 := *types.FixtureEvent("web1", "http")
+	evt2 := *types.FixtureEvent("web2", "http")
+	evt3 := *types.FixtureEvent("db1", "disk")
+	evt4 := *types.FixtureEvent("db2", "disk")
+
+	evt1.Check.Status = sensu.CheckStateOK
+	evt2.Check.Status = sensu.CheckStateWarning
+	evt3.Check.Status = sensu.CheckStateCritical
+	evt4.Check.Status = sensu.CheckStateCritical
+	evt4.Check.Silenced = []string{"maintenance"}
+
+	view := newTopView()
+	view.setEvents([]types.Event{evt1, evt2, evt3, evt4}, nil)
+
+	// Worst entities first by default
+	assert.Equal([]string{"db1", "web2", "db2", "web1"}, view.entities())
+
+	// Sort by name, pressing the key again reverses the order
+	view.handleKey("1")
+	assert.Equal([]string{"db1", "db2", "web1", "web2"}, view.entities())
+	view.handleKey("1")
+	assert.Equal([]string{"web2", "web1", "db2", "db1"}, view.entities())
+
+	// Visibility toggles
+	view.handleKey("o")
+	assert.Equal([]string{"web2", "db1"}, view.entities())
+	view.handleKey("o")
+	view.handleKey("m")
+	assert.Equal([]string{"web2", "web1", "db1"}, view.entities())
+}
+
+func TestTopViewSearch(t *testing.T) {
+	assert := assert.New(t)
+
+	evt1 := *types.FixtureEvent("web1", "http")
+	evt2 := *types.FixtureEvent("web2", "http")
+	evt3 := *types.FixtureEvent("db1", "disk")
+	evt4 := *types.FixtureEvent("db2", "disk")
+
+	evt1.Check.Status = sensu.CheckStateOK
+	evt2.Check.Status = sensu.CheckStateWarning
+	evt3.Check.Status = sensu.CheckStateCritical
+	evt4.Check.Status = sensu.CheckStateCritical
+	evt4.Check.Silenced = []string{"maintenance"}
+
+	view := newTopView()
+	view.setEvents([]types.Event{evt1, evt2, evt3, evt4}, nil)
+
+	view.handleKey("/")
+	assert.True(view.searching)
+	for _, key := range []string{"w", "e", "x", "backspace", "b"} {
+		assert.Equal(topActionNone, view.handleKey(key))
+	}
+	view.handleKey("enter")
+	assert.False(view.searching)
+	assert.Equal("web", view.search)
+	assert.Equal([]string{"web2", "web1"}, view.entities())
+
+	// Escape clears the search
+	view.handleKey("esc")
+	assert.Len(view.entities(), 4)
+}
+
+func TestTopViewDetail(t *testing.T) {
+	assert := assert.New(t)
+
+	evt1 := *types.FixtureEvent("web1", "http")
+	evt2 := *types.FixtureEvent("web2", "http")
+	evt3 := *types.FixtureEvent("db1", "disk")
+	evt4 := *types.FixtureEvent("db2", "disk")
+
+	evt1.Check.Status = sensu.CheckStateOK
+	evt2.Check.Status = sensu.CheckStateWarning
+	evt3.Check.Status = sensu.CheckStateCritical
+	evt4.Check.Status = sensu.CheckStateCritical
+	evt4.Check.Silenced = []string{"maintenance"}
+
+	view := newTopView()
+	view.setEvents([]types.Event{evt1, evt2, evt3, evt4}, nil)
+	view.render(80)
+
+	view.handleKey("down")
+	assert.Equal("web2", view.selected)
+	view.handleKey("enter")
+	assert.Equal("web2", view.detail)
+	assert.Len(view.entityEvents(), 1)
+	assert.Equal(topActionRefresh, view.handleKey("r"))
+
+	// q leaves the detail view first, then quits
+	assert.Equal(topActionNone, view.handleKey("q"))
+	assert.Empty(view.detail)
+	assert.Equal(topActionQuit, view.handleKey("q"))
+}
+
+func TestTopViewKeepsPreviousDataOnError(t *testing.T) {
+	assert := assert.New(t)
+
+	evt1 := *types.FixtureEvent("web1", "http")
+	evt2 := *types.FixtureEvent("web2", "http")
+	evt3 := *types.FixtureEvent("db1", "disk")
+	evt4 := *types.FixtureEvent("db2", "disk")
+
+	evt1.Check.Status = sensu.CheckStateOK
+	evt2.Check.Status = sensu.CheckStateWarning
+	evt3.Check.Status = sensu.CheckStateCritical
+	evt4.Check.Status = sensu.CheckStateCritical
+	evt4.Check.Silenced = []string{"maintenance"}
+
+	view := newTopView()
+	view.setEvents([]types.Event{evt1, evt2, evt3, evt4}, nil)
+	view.setEvents(nil, errors.New("backend unavailable"))
+
+	assert.Error(view.err)
+	assert.Len(view.statusMap, 4)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	customSensu "las/accs/entities-status/sensu"
+
+	"github.com/sensu/sensu-go/types"
+	"github.com/sensu/sensu-plugin-sdk/sensu"
+)
+
+// clearScreen : ANSI sequence moving the cursor home and clearing the terminal
+const clearScreen = "\033[H\033[2J"
+
+// executeWatch : Refresh and print the entities status every interval until SIGINT or SIGTERM
+func executeWatch(interval time.Duration) (int, error) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var previous map[string]customSensu.EntityStatus
+	for {
+		var evts []types.Event
+		agg, err := collectStatus(ctx, keepEvents(&evts))
+		if ctx.Err() != nil {
+			return sensu.CheckStateOK, nil
+		} else if err != nil {
+			// Keep watching, the backend may only be temporarily unavailable
+			fmt.Fprintf(os.Stderr, "Error refreshing entities status: %v\n", err)
+		} else {
+			current := agg.Snapshot(config.Namespace)
+			if err := recordHistory(agg, current); err != nil {
+				fmt.Fprintf(os.Stderr, "Error recording history: %v\n", err)
+			}
+			if err := printWatchResult(newFormatData(evts, current, agg.Info(config.Namespace)), previous, interval); err != nil {
+				fmt.Fprintf(os.Stderr, "Error printing entities status: %v\n", err)
+			}
+			previous = current
+		}
+
+		select {
+		case <-ctx.Done():
+			return sensu.CheckStateOK, nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func printWatchResult(data customSensu.FormatData, previous map[string]customSensu.EntityStatus, interval time.Duration) error {
+	current := data.StatusMap
+	if len(config.OutputFile) > 0 {
+		// The file is replaced at every refresh, there is no screen to draw
+		return printResult(data)
+	}
+
+	// Redrawing only makes sense on a terminal, redirected output gets one refresh after the other
+	if stdoutIsTerminal() {
+		fmt.Print(clearScreen)
+	}
+	fmt.Printf("Every %s: %s/%s\t%s\n\n", interval, sourceName(), config.Namespace, time.Now().Format(time.RFC1123))
+
+	if !isTabularFormat() || hasCustomOutput() {
+		return printResult(data)
+	}
+
+	// Keep the marker column from the first refresh on
+	changed := map[string]bool{}
+	if previous != nil {
+		changed = customSensu.GetChangedEntities(previous, current)
+	}
+	data.Tabular.Changed = changed
+	if err := printResult(data); err != nil {
+		return err
+	}
+
+	var removed []string
+	for entity := range changed {
+		if _, ok := current[entity]; !ok {
+			removed = append(removed, entity)
+		}
+	}
+	if len(removed) > 0 {
+		sort.Strings(removed)
+		fmt.Printf("\nRemoved: %s\n", strings.Join(removed, ", "))
+	}
+	return nil
+}